@@ -0,0 +1,77 @@
+package ki
+
+import (
+	"strconv"
+	"strings"
+)
+
+// acceptRange es un media range del header Accept (ej. text/*;q=0.8).
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mt, params, _ := strings.Cut(part, ";")
+		typ, sub, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mt)), "/")
+		if typ == "" {
+			continue
+		}
+		if sub == "" {
+			sub = "*"
+		}
+		r := acceptRange{typ: typ, sub: sub, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// quality devuelve la q del range más específico que acepta el tipo (-1 si ninguno).
+func quality(ranges []acceptRange, mediaType string) float64 {
+	typ, sub, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	q, best := -1.0, 0
+	for _, r := range ranges {
+		spec := 0
+		switch {
+		case r.typ == typ && r.sub == sub:
+			spec = 3
+		case r.typ == typ && r.sub == "*":
+			spec = 2
+		case r.typ == "*" && r.sub == "*":
+			spec = 1
+		}
+		if spec > best {
+			q, best = r.q, spec
+		}
+	}
+	return q
+}
+
+// negotiate elige la oferta con mayor q según el header Accept; en empate gana
+// la primera oferta. Sin Accept devuelve la primera y "" si ninguna es aceptable.
+func negotiate(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
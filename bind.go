@@ -0,0 +1,311 @@
+package ki
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxMemory es la memoria usada por ParseMultipartForm (igual que net/http).
+const defaultMaxMemory = 32 << 20
+
+// bindSources son los tags de struct que Bind lee de la petición, en orden.
+var bindSources = []string{"path", "query", "form", "header"}
+
+// FieldError describe un campo que no se pudo asignar durante Bind.
+type FieldError struct {
+	Field  string // nombre del campo en el struct ("" para errores del cuerpo)
+	Source string // path, query, form, header o body
+	Key    string // nombre en la petición
+	Value  string
+	Err    error
+}
+
+// MarshalJSON expone el error como "message" (usado por la respuesta 400 por defecto).
+func (e FieldError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Field   string `json:"field,omitempty"`
+		Source  string `json:"source"`
+		Key     string `json:"key,omitempty"`
+		Value   string `json:"value,omitempty"`
+		Message string `json:"message"`
+	}{e.Field, e.Source, e.Key, e.Value, e.Err.Error()})
+}
+
+func (e FieldError) Error() string {
+	if e.Source == "body" {
+		return fmt.Sprintf("body: %v", e.Err)
+	}
+	return fmt.Sprintf("%s %q=%q: %v", e.Source, e.Key, e.Value, e.Err)
+}
+
+// BindError agrupa todos los campos que fallaron en Bind.
+type BindError struct {
+	Fields []FieldError
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "ki: bind: " + strings.Join(msgs, "; ")
+}
+
+func (e *BindError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// Bind llena dst (puntero a struct) con los datos de la petición. El cuerpo se
+// decodifica según el Content-Type (JSON, XML, urlencoded o multipart) y luego
+// se aplican los tags path, query, form y header:
+//
+//	type Input struct {
+//		ID     int       `path:"id"`
+//		Page   int       `query:"page"`
+//		Name   string    `form:"name" json:"name"`
+//		Tenant string    `header:"X-Tenant"`
+//		Avatar *multipart.FileHeader `form:"avatar"`
+//	}
+//
+// Si algún campo falla devuelve un *BindError con todos los errores.
+func (s *Context) Bind(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ki: Bind requiere un puntero a struct, se recibió %T", dst)
+	}
+	bErr := &BindError{}
+	if err := s.bindBody(dst); err != nil {
+		bErr.Fields = append(bErr.Fields, FieldError{Source: "body", Err: err})
+	}
+	s.bindStruct(rv.Elem(), bErr)
+	if len(bErr.Fields) > 0 {
+		return bErr
+	}
+	return nil
+}
+
+// bindBody decodifica el cuerpo según su Content-Type.
+func (s *Context) bindBody(dst any) error {
+	r := s.Request
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		if err := json.NewDecoder(r.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	case ct == "application/xml" || ct == "text/xml" || strings.HasSuffix(ct, "+xml"):
+		if err := xml.NewDecoder(r.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	case ct == "multipart/form-data":
+		_, err := s.multipartForm()
+		return err
+	case ct == "application/x-www-form-urlencoded":
+		if s.formErr != nil {
+			return s.formErr
+		}
+		return r.ParseForm()
+	}
+	return nil
+}
+
+func (s *Context) bindStruct(v reflect.Value, bErr *BindError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Anonymous && fv.Kind() == reflect.Struct {
+			s.bindStruct(fv, bErr)
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		for _, source := range bindSources {
+			key := tagName(sf.Tag.Get(source))
+			if key == "" {
+				continue
+			}
+			if source == "form" && s.bindFile(fv, key) {
+				continue
+			}
+			raw := s.sourceValues(source, key)
+			if len(raw) == 0 {
+				continue
+			}
+			if err := setField(fv, raw); err != nil {
+				bErr.Fields = append(bErr.Fields, FieldError{
+					Field:  sf.Name,
+					Source: source,
+					Key:    key,
+					Value:  strings.Join(raw, ","),
+					Err:    err,
+				})
+			}
+		}
+	}
+}
+
+func (s *Context) sourceValues(source, key string) []string {
+	r := s.Request
+	switch source {
+	case "path":
+		if v, ok := s.params[key]; ok {
+			return []string{v}
+		}
+	case "query":
+		return r.URL.Query()[key]
+	case "form":
+		return r.Form[key]
+	case "header":
+		return r.Header.Values(key)
+	}
+	return nil
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindFile asigna archivos multipart a campos *multipart.FileHeader o []*multipart.FileHeader.
+func (s *Context) bindFile(fv reflect.Value, key string) bool {
+	if fv.Type() != fileHeaderType && fv.Type() != fileHeadersType {
+		return false
+	}
+	mf := s.Request.MultipartForm
+	if mf == nil || len(mf.File[key]) == 0 {
+		return true
+	}
+	if fv.Type() == fileHeaderType {
+		fv.Set(reflect.ValueOf(mf.File[key][0]))
+	} else {
+		fv.Set(reflect.ValueOf(mf.File[key]))
+	}
+	return true
+}
+
+// ----------- CONVERSIÓN DE VALORES -----------
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setField asigna los valores crudos al campo; los slices reciben todos los valores.
+func setField(f reflect.Value, raw []string) error {
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(f.Type()).Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(f.Type(), len(raw), len(raw))
+		for i, r := range raw {
+			if err := setScalar(s.Index(i), r); err != nil {
+				return err
+			}
+		}
+		f.Set(s)
+		return nil
+	}
+	return setScalar(f, raw[0])
+}
+
+func setScalar(f reflect.Value, s string) error {
+	if f.Kind() == reflect.Ptr {
+		v := reflect.New(f.Type().Elem())
+		if err := setScalar(v.Elem(), s); err != nil {
+			return err
+		}
+		f.Set(v)
+		return nil
+	}
+	switch f.Type() {
+	case timeType:
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	if f.CanAddr() {
+		if tu, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(v)
+	default:
+		return fmt.Errorf("tipo no soportado %s", f.Type())
+	}
+	return nil
+}
+
+// parseTime acepta RFC3339, fecha y fecha-hora.
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// tagName devuelve el nombre del tag sin opciones ("" si se ignora).
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,131 @@
+package ki
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrBodyTooSlow indica que el cliente envía el cuerpo más lento que el mínimo
+// de MinReadRate. Responde 408.
+var ErrBodyTooSlow = errors.New("ki: el cuerpo llega demasiado lento")
+
+type bodyLimit struct {
+	max     int64         // bytes; <= 0 sin límite
+	minRate int64         // bytes por segundo; 0 sin control
+	grace   time.Duration // tiempo antes de exigir minRate
+}
+
+// BodyLimitOption configura BodyLimit.
+type BodyLimitOption func(l *bodyLimit)
+
+// MinReadRate exige que, pasado grace, el cuerpo llegue a bytesPerSecond en
+// promedio. Corta uploads tipo slowloris sin afectar a clientes lentos pero
+// constantes.
+func MinReadRate(bytesPerSecond int64, grace time.Duration) BodyLimitOption {
+	return func(l *bodyLimit) {
+		l.minRate = bytesPerSecond
+		l.grace = grace
+	}
+}
+
+func newBodyLimit(max int64, opts []BodyLimitOption) *bodyLimit {
+	l := &bodyLimit{max: max}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// BodyLimit limita el cuerpo de las peticiones a max bytes con
+// http.MaxBytesReader; al superarlo la lectura falla y el error responde 413
+// por el pipeline de errores. Un Content-Length mayor se rechaza sin leer.
+//
+// Como middleware se aplica después de que Ki lea los formularios
+// x-www-form-urlencoded (net/http ya los limita a 10 MB); para cubrirlos usa
+// ki.SetBodyLimit, GroupRouter.BodyLimit o RouteBuilder.BodyLimit.
+//
+//	api.Use(ki.BodyLimit(1<<20, ki.MinReadRate(1024, 5*time.Second)))
+func BodyLimit(max int64, opts ...BodyLimitOption) Middleware {
+	l := newBodyLimit(max, opts)
+	return func(ctx *Context) error {
+		if err := l.apply(ctx.Writer, ctx.Request); err != nil {
+			return err
+		}
+		return ctx.Next()
+	}
+}
+
+// apply envuelve r.Body; l puede ser nil.
+func (l *bodyLimit) apply(w http.ResponseWriter, r *http.Request) error {
+	if l == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if l.max > 0 && r.ContentLength > l.max {
+		return &http.MaxBytesError{Limit: l.max}
+	}
+	if l.minRate > 0 {
+		r.Body = &slowBodyReader{
+			ReadCloser: r.Body,
+			rc:         http.NewResponseController(w),
+			start:      time.Now(),
+			minRate:    l.minRate,
+			grace:      l.grace,
+		}
+	}
+	if l.max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, l.max)
+	}
+	return nil
+}
+
+// slowBodyReader mueve el read deadline de la conexión según lo recibido, así
+// también corta a un cliente que deja de enviar. Si el ResponseWriter no
+// soporta deadlines sólo detecta a los clientes lentos al leer.
+type slowBodyReader struct {
+	io.ReadCloser
+	rc         *http.ResponseController
+	start      time.Time
+	n          int64
+	minRate    int64
+	grace      time.Duration
+	noDeadline bool
+}
+
+// deadline es cuándo el promedio recibido cae por debajo de minRate. Se
+// calcula en float: n*time.Second desborda int64 pasados ~9 GB.
+func (b *slowBodyReader) deadline() time.Time {
+	return b.start.Add(b.grace + time.Duration(float64(b.n)/float64(b.minRate)*float64(time.Second)))
+}
+
+func (b *slowBodyReader) Read(p []byte) (int, error) {
+	if !b.noDeadline {
+		b.noDeadline = b.rc.SetReadDeadline(b.deadline()) != nil
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return n, ErrBodyTooSlow
+	case err == io.EOF:
+		b.clearDeadline()
+	case err == nil && time.Now().After(b.deadline()):
+		return n, ErrBodyTooSlow
+	}
+	return n, err
+}
+
+func (b *slowBodyReader) Close() error {
+	b.clearDeadline()
+	return b.ReadCloser.Close()
+}
+
+// clearDeadline quita el deadline para no cortar la conexión después del cuerpo.
+func (b *slowBodyReader) clearDeadline() {
+	if !b.noDeadline {
+		b.rc.SetReadDeadline(time.Time{})
+		b.noDeadline = true
+	}
+}
@@ -23,31 +23,39 @@ func cacheMiddleware(duration time.Duration) Middleware {
 	var mu sync.Mutex
 	var cache *cacheEntry
 
-	return func(ctx *Context) {
+	return func(ctx *Context) error {
 		mu.Lock()
-		defer mu.Unlock()
-		now := time.Now()
-		if cache != nil && now.Before(cache.expiresAt) {
+		entry := cache
+		mu.Unlock()
+		if entry != nil && time.Now().Before(entry.expiresAt) {
 			// Sirve respuesta cacheada
-			for k, vals := range cache.header {
+			for k, vals := range entry.header {
 				for _, v := range vals {
 					ctx.Writer.Header().Add(k, v)
 				}
 			}
-			ctx.Writer.WriteHeader(cache.status)
-			ctx.Writer.Write(cache.content)
-			return
+			ctx.Writer.WriteHeader(entry.status)
+			ctx.Writer.Write(entry.content)
+			return nil
 		}
-		// Captura respuesta del handler
+		// Captura respuesta del handler (sin bloquear: puede ser un stream)
 		rec := &responseRecorder{ResponseWriter: ctx.Writer, header: make(http.Header)}
 		ctx.Writer = rec
-		ctx.Next() // Ejecuta el resto del pipeline (handler)
+		err := ctx.Next() // Ejecuta el resto del pipeline (handler)
+		ctx.Writer = rec.ResponseWriter
+		// Los errores se responden después por el pipeline: no hay nada que guardar
+		if err != nil || rec.bypass || rec.status == 0 {
+			return err
+		}
+		mu.Lock()
 		cache = &cacheEntry{
 			content:   append([]byte(nil), rec.body...), // copia defensiva
 			status:    rec.status,
 			header:    rec.header.Clone(),
 			expiresAt: time.Now().Add(duration),
 		}
+		mu.Unlock()
+		return nil
 	}
 }
 
@@ -57,16 +65,48 @@ type responseRecorder struct {
 	header http.Header
 	body   []byte
 	status int
+	bypass bool // respuesta en streaming: no se guarda ni se acumula el cuerpo
 }
 
 func (r *responseRecorder) Header() http.Header {
+	if r.bypass {
+		return r.ResponseWriter.Header()
+	}
 	return r.header
 }
 func (r *responseRecorder) Write(b []byte) (int, error) {
-	r.body = append(r.body, b...)
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if !r.bypass {
+		r.body = append(r.body, b...)
+	}
 	return r.ResponseWriter.Write(b)
 }
 func (r *responseRecorder) WriteHeader(code int) {
 	r.status = code
 	r.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap permite a http.ResponseController llegar al writer original (Flush).
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// skipCache desactiva la caché de la respuesta en curso; los headers ya
+// puestos pasan al writer original.
+func skipCache(w http.ResponseWriter) {
+	for w != nil {
+		if rec, ok := w.(*responseRecorder); ok && !rec.bypass {
+			for k, v := range rec.header {
+				rec.ResponseWriter.Header()[k] = v
+			}
+			rec.bypass = true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,181 @@
+package ki
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// RouteConflictMode define qué hacer al registrar una ruta duplicada o ambigua.
+type RouteConflictMode int
+
+const (
+	// RoutesLog registra el conflicto con log.Printf (por defecto).
+	RoutesLog RouteConflictMode = iota
+	// RoutesIgnore mantiene el comportamiento silencioso anterior.
+	RoutesIgnore
+	// RoutesPanic hace panic al registrar la ruta.
+	RoutesPanic
+	// RoutesError acumula el error en RouteBuilder.Err y App.RouteErrors.
+	RoutesError
+)
+
+// StrictRoutes configura la detección de rutas duplicadas o ambiguas. Por
+// defecto (RoutesLog) cada conflicto se registra con log.Printf; usa
+// RoutesIgnore para silenciarlos. En todos los modos que no hacen panic la
+// ruta se registra igual y gana la primera.
+func StrictRoutes(mode RouteConflictMode) Option {
+	return func(o *options) {
+		o.StrictRoutes = mode
+	}
+}
+
+// RouteConflictError describe una ruta que choca con otra ya registrada.
+type RouteConflictError struct {
+	Method         string
+	Pattern        string
+	Source         string
+	ExistingMethod string
+	Existing       string
+	ExistingSource string
+	// Duplicate es true si el patrón es idéntico; false si sólo es ambiguo
+	// (ej. /user/:id y /user/{name} sin regex que los distinga).
+	Duplicate bool
+}
+
+func (e *RouteConflictError) Error() string {
+	kind := "ambigua"
+	if e.Duplicate {
+		kind = "duplicada"
+	}
+	return fmt.Sprintf("ki: ruta %s %s %s (%s) choca con %s %s (%s)",
+		kind, methodLabel(e.Method), e.Pattern, e.Source,
+		methodLabel(e.ExistingMethod), e.Existing, e.ExistingSource)
+}
+
+// RouteErrors devuelve los conflictos acumulados en modo RoutesError.
+func (app *App) RouteErrors() error {
+	return errors.Join(app.routeErrs...)
+}
+
+// Err devuelve el conflicto de la última ruta registrada con este builder.
+func (rb *RouteBuilder) Err() error {
+	return rb.err
+}
+
+// checkConflict busca una ruta previa con la misma forma (dominio, headers y
+// segmentos sin contar los nombres de las variables) y el mismo método; una
+// ruta ANY (Handle/Any) choca con las de cualquier método.
+// Las rutas con segmentos opcionales se comparan en cada una de sus variantes.
+func (r *router) checkConflict(rt *route) error {
+	var prev *route
+	for _, key := range routeShapes(rt) {
+		registered := false
+		for _, p := range r.shapes[key] {
+			registered = registered || p.method == rt.method
+			if prev == nil && (p.method == rt.method || p.method == "" || rt.method == "") {
+				prev = p
+			}
+		}
+		if !registered {
+			r.shapes[key] = append(r.shapes[key], rt)
+		}
+	}
+	if prev == nil {
+		return nil
+	}
+	return &RouteConflictError{
+		Method:         rt.method,
+		Pattern:        rt.pattern,
+		Source:         rt.source,
+		ExistingMethod: prev.method,
+		Existing:       prev.pattern,
+		ExistingSource: prev.source,
+		Duplicate:      prev.pattern == rt.pattern,
+	}
+}
+
+func (r *router) reportConflict(rb *RouteBuilder, err error) {
+	rb.err = err
+	switch r.app.strictRoutes {
+	case RoutesIgnore:
+	case RoutesPanic:
+		panic(err)
+	case RoutesError:
+		r.app.routeErrs = append(r.app.routeErrs, err)
+	default:
+		log.Print(err)
+	}
+}
+
+// routeShapes normaliza la ruta para comparar: las variables se reducen a su
+// regex. Devuelve una forma por cada longitud posible (segmentos opcionales).
+func routeShapes(rt *route) []string {
+	var b strings.Builder
+	b.WriteString(rt.domain)
+	b.WriteByte('|')
+	b.WriteString(joinPairs(rt.headers))
+	b.WriteByte('|')
+	if rt.isPrefix {
+		b.WriteString("prefix:")
+		b.WriteString(rt.prefix)
+		return []string{b.String()}
+	}
+	var shapes []string
+	for _, seg := range rt.segments {
+		ps := parseSegment(seg)
+		if ps.optional {
+			shapes = append(shapes, b.String())
+		}
+		b.WriteByte('/')
+		switch ps.kind {
+		case segStatic:
+			b.WriteString(seg)
+		case segParam:
+			b.WriteString(":(" + rt.varRegex(ps.name, ps.expr) + ")")
+		case segCatchAll:
+			b.WriteString("*(" + rt.varRegex(ps.name, ps.expr) + ")")
+		case segMixed:
+			for _, p := range ps.parts {
+				if p.name == "" {
+					b.WriteString(p.literal)
+				} else {
+					b.WriteString("{(" + rt.varRegex(p.name, p.expr) + ")}")
+				}
+			}
+		}
+	}
+	return append(shapes, b.String())
+}
+
+// kiDir es el directorio del paquete, para saltar sus frames al buscar el origen.
+var kiDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerLocation devuelve file:line del primer frame fuera del paquete ki.
+func callerLocation() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if filepath.Dir(f.File) != kiDir || strings.HasSuffix(f.File, "_test.go") {
+			return filepath.Base(f.File) + ":" + fmt.Sprint(f.Line)
+		}
+		if !more {
+			return "desconocido"
+		}
+	}
+}
+
+func methodLabel(method string) string {
+	if method == "" {
+		return "ANY"
+	}
+	return method
+}
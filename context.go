@@ -30,6 +30,10 @@ type Context struct {
 	App      *App
 	next     func() error
 	params   map[string]string
+	values   map[string]any // parámetros convertidos ({id:int})
+	finish   []func()       // se ejecutan al terminar el pipeline
+	route    *route         // ruta que atiende la petición
+	formErr  error          // error de ParseForm (ej. cuerpo demasiado grande)
 }
 
 func NewContext(ctx context.Context, app *App, w http.ResponseWriter, r *http.Request) *Context {
@@ -71,7 +75,7 @@ func UseContext(app *App, w http.ResponseWriter, r *http.Request) (*Context, err
 		),
 	)
 
-	r.ParseForm()
+	c.formErr = r.ParseForm()
 	err := c.Session.Start(r.Context(), w, r)
 
 	return c, err
@@ -139,11 +143,16 @@ func (s *Context) Success(message string, args ...any) {
 }
 
 // response standard for results like fail
+// Con UseProblemDetails se responde como problem+json (body va en las extensiones).
 func (s *Context) Fail(code int, err error, args ...any) {
 	var body any
 	if len(args) == 1 {
 		body = args[0]
 	}
+	if s.App != nil && s.App.problems != nil {
+		s.Problem(&HTTPError{Status: code, Message: err.Error(), Details: body, Cause: err})
+		return
+	}
 	s.JSON(code, H{
 		"meta": H{
 			"success": false,
@@ -176,6 +185,18 @@ func (s *Context) RedirectHTML(url string, code int) {
 	`, url))
 }
 
+// onFinish registra fn para cuando termine el pipeline de la petición.
+func (c *Context) onFinish(fn func()) {
+	c.finish = append(c.finish, fn)
+}
+
+func (c *Context) runFinish() {
+	for i := len(c.finish) - 1; i >= 0; i-- {
+		c.finish[i]()
+	}
+	c.finish = nil
+}
+
 func (c *Context) Next() error {
 	if c.next != nil {
 		return c.next()
@@ -212,6 +233,22 @@ func (s *Context) Vars() map[string]string {
 	return s.params
 }
 
+// setParams guarda los parámetros capturados por el router. Siempre reemplaza
+// los anteriores: en routers anidados el Context se reutiliza.
+func (s *Context) setParams(ps []pathParam) {
+	s.params = make(map[string]string, len(ps))
+	s.values = nil
+	for _, p := range ps {
+		s.params[p.key] = p.value
+		if p.converted != nil && p.err == nil {
+			if s.values == nil {
+				s.values = make(map[string]any)
+			}
+			s.values[p.key] = p.converted
+		}
+	}
+}
+
 // Setea un header de respuesta
 func (s *Context) SetHeader(key, value string) {
 	s.Writer.Header().Set(key, value)
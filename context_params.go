@@ -2,12 +2,17 @@ package ki
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 	// "github.com/gorilla/mux"
 )
 
 func (s *Context) DecodeJSON(data any) error {
-	return json.NewDecoder(s.Request.Body).Decode(&data)
+	return json.NewDecoder(s.Request.Body).Decode(data)
 }
 
 // func (s *Context) Vars() map[string]string {
@@ -192,3 +197,112 @@ func (s *Context) GetBoolArray(key string) (results []bool) {
 	}
 	return
 }
+
+// ---------- PARÁMETROS DE RUTA (TIPADOS) ----------
+
+// ErrParamNotFound indica que la ruta no capturó el parámetro pedido.
+var ErrParamNotFound = errors.New("parámetro no encontrado")
+
+// ParamError describe un parámetro de ruta ausente o con formato inválido.
+type ParamError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("ki: parámetro %q=%q: %v", e.Name, e.Value, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+var uuidPattern = regexp.MustCompile(`^(?:` + uuidRegex + `)$`)
+
+// Param devuelve el valor crudo del parámetro de ruta.
+func (s *Context) Param(name string) (string, error) {
+	v, ok := s.params[name]
+	if !ok {
+		return "", &ParamError{Name: name, Err: ErrParamNotFound}
+	}
+	return v, nil
+}
+
+// ParamValue devuelve el valor producido por el converter del parámetro ({id:int}).
+func (s *Context) ParamValue(name string) (any, bool) {
+	v, ok := s.values[name]
+	return v, ok
+}
+
+func (s *Context) ParamInt(name string) (int, error) {
+	if v, ok := s.values[name].(int); ok {
+		return v, nil
+	}
+	return parseParam(s, name, strconv.Atoi)
+}
+
+func (s *Context) ParamInt64(name string) (int64, error) {
+	if v, ok := s.values[name].(int64); ok {
+		return v, nil
+	}
+	return parseParam(s, name, func(v string) (int64, error) {
+		return strconv.ParseInt(v, 10, 64)
+	})
+}
+
+func (s *Context) ParamUint64(name string) (uint64, error) {
+	if v, ok := s.values[name].(uint64); ok {
+		return v, nil
+	}
+	return parseParam(s, name, func(v string) (uint64, error) {
+		return strconv.ParseUint(v, 10, 64)
+	})
+}
+
+func (s *Context) ParamFloat64(name string) (float64, error) {
+	if v, ok := s.values[name].(float64); ok {
+		return v, nil
+	}
+	return parseParam(s, name, func(v string) (float64, error) {
+		return strconv.ParseFloat(v, 64)
+	})
+}
+
+func (s *Context) ParamBool(name string) (bool, error) {
+	if v, ok := s.values[name].(bool); ok {
+		return v, nil
+	}
+	return parseParam(s, name, strconv.ParseBool)
+}
+
+// ParamUUID valida el formato 8-4-4-4-12 y devuelve el UUID en minúsculas.
+func (s *Context) ParamUUID(name string) (string, error) {
+	return parseParam(s, name, func(v string) (string, error) {
+		if !uuidPattern.MatchString(v) {
+			return "", errors.New("uuid inválido")
+		}
+		return strings.ToLower(v), nil
+	})
+}
+
+// ParamTime parsea el parámetro con el layout dado (ej. time.DateOnly). Usa
+// siempre layout, aunque un converter ({at:date}) ya haya parseado el valor.
+func (s *Context) ParamTime(name, layout string) (time.Time, error) {
+	return parseParam(s, name, func(v string) (time.Time, error) {
+		return time.Parse(layout, v)
+	})
+}
+
+func parseParam[T any](s *Context, name string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	raw, err := s.Param(name)
+	if err != nil {
+		return zero, err
+	}
+	v, err := parse(raw)
+	if err != nil {
+		return zero, &ParamError{Name: name, Value: raw, Err: err}
+	}
+	return v, nil
+}
@@ -0,0 +1,64 @@
+package ki
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Converter es un tipo de parámetro con nombre usable en los patrones:
+// {id:int}, {slug:slug}, {at:date}. Regex se usa para el matching y Convert
+// para obtener el valor tipado; si Convert falla (ej. un int fuera de rango)
+// la ruta responde 400 con un ParamError.
+type Converter struct {
+	Regex   string
+	Convert func(string) (any, error)
+}
+
+// uuidRegex es el formato 8-4-4-4-12 (también lo usa ctx.ParamUUID).
+const uuidRegex = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+
+// Converters incluidos por defecto en cada App.
+var defaultConverters = map[string]*Converter{
+	"int": {Regex: `-?[0-9]+`, Convert: func(s string) (any, error) {
+		return strconv.Atoi(s)
+	}},
+	"int64": {Regex: `-?[0-9]+`, Convert: func(s string) (any, error) {
+		return strconv.ParseInt(s, 10, 64)
+	}},
+	"uint": {Regex: `[0-9]+`, Convert: func(s string) (any, error) {
+		return strconv.ParseUint(s, 10, 64)
+	}},
+	"float": {Regex: `-?[0-9]+(?:\.[0-9]+)?`, Convert: func(s string) (any, error) {
+		return strconv.ParseFloat(s, 64)
+	}},
+	"bool": {Regex: `true|false|1|0`, Convert: func(s string) (any, error) {
+		return strconv.ParseBool(s)
+	}},
+	"slug": {Regex: `[a-z0-9]+(?:-[a-z0-9]+)*`, Convert: func(s string) (any, error) {
+		return s, nil
+	}},
+	"uuid": {Regex: uuidRegex, Convert: func(s string) (any, error) {
+		return strings.ToLower(s), nil
+	}},
+	"date": {Regex: `[0-9]{4}-[0-9]{2}-[0-9]{2}`, Convert: func(s string) (any, error) {
+		return time.Parse(time.DateOnly, s)
+	}},
+}
+
+// RegisterConverter agrega (o reemplaza) un converter de parámetros.
+// Debe llamarse antes de registrar las rutas que lo usan.
+//
+//	app.RegisterConverter("hex", &ki.Converter{Regex: "[0-9a-f]+", Convert: parseHex})
+//	app.Get("/color/{c:hex}", h)
+func (app *App) RegisterConverter(name string, c *Converter) {
+	app.converters[name] = c
+}
+
+func copyConverters(m map[string]*Converter) map[string]*Converter {
+	out := make(map[string]*Converter, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
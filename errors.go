@@ -1,11 +1,21 @@
 package ki
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
 // ErrorHandler es la función para capturar errores globales.
 type ErrorHandler func(ctx *Context, err error)
 
 // NotFoundHandler es la función para manejar rutas no encontradas.
 type NotFoundHandler func(ctx *Context)
 
+// MethodNotAllowedHandler maneja rutas cuyo path existe pero no con el método pedido.
+// Al invocarse, el header Allow ya está establecido en la respuesta.
+type MethodNotAllowedHandler func(ctx *Context)
+
 // Helpers para App (globales)
 func (app *App) OnError(fn ErrorHandler) {
 	app.onError = fn
@@ -13,5 +23,148 @@ func (app *App) OnError(fn ErrorHandler) {
 func (app *App) NotFound(fn NotFoundHandler) {
 	app.notFound = fn
 }
+func (app *App) MethodNotAllowed(fn MethodNotAllowedHandler) {
+	app.notAllowed = fn
+}
 
 // En RouteBuilder y GroupRouter ya están los setters por scope.
+
+// ----------- ERRORES HTTP -----------
+
+// HTTPError es un error con status HTTP. Si un handler, middleware o panic lo
+// produce (directo o envuelto) y no hay OnError, se responde con su status y el
+// formato de ctx.Fail. Cause no se expone en la respuesta.
+type HTTPError struct {
+	Status  int
+	Code    string // código propio de la aplicación (opcional)
+	Message string
+	Details any
+	Cause   error
+}
+
+// NewHTTPError crea un HTTPError; sin mensaje usa http.StatusText(status).
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+// Constructores para los status más comunes.
+func BadRequest(message string) *HTTPError    { return NewHTTPError(http.StatusBadRequest, message) }
+func Unauthorized(message string) *HTTPError  { return NewHTTPError(http.StatusUnauthorized, message) }
+func Forbidden(message string) *HTTPError     { return NewHTTPError(http.StatusForbidden, message) }
+func NotFoundError(message string) *HTTPError { return NewHTTPError(http.StatusNotFound, message) }
+func Conflict(message string) *HTTPError      { return NewHTTPError(http.StatusConflict, message) }
+func InternalError(cause error) *HTTPError {
+	return NewHTTPError(http.StatusInternalServerError, "").WithCause(cause)
+}
+
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	e.Code = code
+	return e
+}
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	e.Details = details
+	return e
+}
+func (e *HTTPError) WithCause(cause error) *HTTPError {
+	e.Cause = cause
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// ErrorMapper traduce un error de dominio a HTTPError; devuelve nil si no aplica.
+type ErrorMapper func(err error) *HTTPError
+
+// MapError registra un mapper que se aplica a todos los errores antes de OnError.
+// El error original queda como Cause, así errors.Is sigue funcionando.
+//
+//	app.MapError(func(err error) *ki.HTTPError {
+//		if errors.Is(err, sql.ErrNoRows) {
+//			return ki.NotFoundError("")
+//		}
+//		return nil
+//	})
+func (app *App) MapError(fn ErrorMapper) {
+	app.errorMappers = append(app.errorMappers, fn)
+}
+
+func (app *App) mapError(err error) error {
+	if len(app.errorMappers) == 0 || errors.As(err, new(*HTTPError)) {
+		return err
+	}
+	for _, fn := range app.errorMappers {
+		if he := fn(err); he != nil {
+			mapped := *he
+			mapped.Cause = err
+			return &mapped
+		}
+	}
+	return err
+}
+
+// ToHTTPError devuelve el HTTPError que corresponde a err: el propio (si está
+// envuelto), 413 para http.MaxBytesError, 408 para ErrBodyTooSlow (aunque
+// vengan dentro de un BindError), 422 para ValidationError, 400 para
+// BindError y ParamError (ctx.ParamInt, ctx.ParamUUID...) y 500 para el resto.
+func ToHTTPError(err error) *HTTPError {
+	if he, ok := httpErrorOf(err); ok {
+		return he
+	}
+	return InternalError(err)
+}
+
+func httpErrorOf(err error) (*HTTPError, bool) {
+	var he *HTTPError
+	var ve *ValidationError
+	var be *BindError
+	var mbe *http.MaxBytesError
+	var pe *ParamError
+	switch {
+	case errors.As(err, &he):
+		return he, true
+	case errors.As(err, &mbe):
+		msg := fmt.Sprintf("el cuerpo supera el límite de %d bytes", mbe.Limit)
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Code: "body_too_large", Message: msg, Cause: err}, true
+	case errors.Is(err, ErrBodyTooSlow):
+		return &HTTPError{Status: http.StatusRequestTimeout, Code: "body_too_slow", Message: ErrBodyTooSlow.Error(), Cause: err}, true
+	case errors.As(err, &ve):
+		return &HTTPError{Status: http.StatusUnprocessableEntity, Code: "validation_failed", Message: ve.Error(), Details: ve.Fields, Cause: ve}, true
+	case errors.As(err, &be):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "bind_failed", Message: be.Error(), Details: be.Fields, Cause: be}, true
+	case errors.As(err, &pe):
+		return &HTTPError{Status: http.StatusBadRequest, Code: "invalid_param", Message: pe.Error(), Cause: pe}, true
+	}
+	return nil, false
+}
+
+// defaultError responde cuando no hay OnError: como problem details si se usó
+// UseProblemDetails; si no, los errores HTTP con el formato de ctx.Fail y el
+// resto como 500 en texto plano con message.
+func defaultError(ctx *Context, err error, message string) {
+	if ctx.App != nil && ctx.App.problems != nil {
+		ctx.Problem(err)
+		return
+	}
+	he, ok := httpErrorOf(err)
+	if !ok {
+		http.Error(ctx.Writer, message, http.StatusInternalServerError)
+		return
+	}
+	meta := H{"success": false, "message": he.Message}
+	if he.Code != "" {
+		meta["code"] = he.Code
+	}
+	ctx.JSON(he.Status, H{"meta": meta, "body": he.Details})
+}
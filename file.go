@@ -0,0 +1,162 @@
+package ki
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// File envía el archivo del sistema de archivos con soporte de Range/If-Range,
+// If-Modified-Since, ETag (If-None-Match) y detección de MIME. El path no debe
+// venir sin validar del cliente; para eso usa FileFS con un fs.FS acotado.
+// Si el archivo no existe devuelve un *HTTPError 404.
+func (s *Context) File(name string) error {
+	f, err := os.Open(name)
+	return s.serveFile(f, err, name, "", "")
+}
+
+// FileFS envía name desde fsys (ej. un embed.FS).
+func (s *Context) FileFS(fsys fs.FS, name string) error {
+	name = strings.TrimPrefix(name, "/")
+	f, err := fsys.Open(name)
+	return s.serveFile(f, err, name, "", "")
+}
+
+// Attachment envía el archivo para descargar como filename (por defecto el
+// nombre del archivo). Los nombres no ASCII se codifican según RFC 6266.
+func (s *Context) Attachment(name, filename string) error {
+	f, err := os.Open(name)
+	return s.serveFile(f, err, name, "attachment", filename)
+}
+
+// Inline envía el archivo para mostrarse en el navegador con el nombre filename.
+func (s *Context) Inline(name, filename string) error {
+	f, err := os.Open(name)
+	return s.serveFile(f, err, name, "inline", filename)
+}
+
+func (s *Context) serveFile(f fs.File, err error, name, disposition, filename string) error {
+	if err != nil {
+		return fileError(name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fileError(name, err)
+	}
+	if info.IsDir() {
+		return NotFoundError("").WithCause(fmt.Errorf("ki: %s es un directorio", name))
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	skipCache(s.Writer)
+	h := s.Writer.Header()
+	if h.Get("Etag") == "" {
+		etag, err := fileETag(info, content)
+		if err != nil {
+			return err
+		}
+		h.Set("Etag", etag)
+	}
+	if disposition != "" {
+		if filename == "" {
+			filename = info.Name()
+		}
+		h.Set("Content-Disposition", ContentDisposition(disposition, filename))
+	}
+	http.ServeContent(s.Writer, s.Request, info.Name(), info.ModTime(), content)
+	return nil
+}
+
+func fileError(name string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		return NotFoundError("").WithCause(err)
+	case errors.Is(err, fs.ErrPermission):
+		return Forbidden("").WithCause(err)
+	}
+	return fmt.Errorf("ki: no se pudo abrir %s: %w", name, err)
+}
+
+// fileETag es fuerte, para que If-Range permita reanudar descargas: tamaño +
+// fecha de modificación, o un hash del contenido si no hay fecha (archivos
+// embebidos).
+func fileETag(info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if mt := info.ModTime(); !mt.IsZero() && mt.Unix() != 0 {
+		return fmt.Sprintf(`"%x-%x"`, info.Size(), mt.UnixNano()), nil
+	}
+	sum := sha1.New()
+	if _, err := io.Copy(sum, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(sum.Sum(nil)[:8]) + `"`, nil
+}
+
+// ContentDisposition arma el header según RFC 6266: filename con una versión
+// ASCII y, si el nombre no es ASCII, filename* en UTF-8.
+//
+//	ContentDisposition("attachment", "año 2024.pdf")
+//	// attachment; filename="ano 2024.pdf"; filename*=UTF-8''a%C3%B1o%202024.pdf
+func ContentDisposition(disposition, filename string) string {
+	fallback := asciiFilename(filename)
+	v := disposition + `; filename="` + fallback + `"`
+	if fallback != filename {
+		v += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return v
+}
+
+// Transliteración de los caracteres acentuados más comunes.
+var asciiReplacer = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u", "ç", "c", "Ç", "C",
+)
+
+func asciiFilename(s string) string {
+	s = asciiReplacer.Replace(s)
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r == 0x7f || r >= utf8.RuneSelf:
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// encodeRFC5987 escapa todo lo que no sea attr-char.
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -27,8 +27,11 @@ func NewGroupRouter(app *App, router *router, base string, parent *RouteBuilder)
 		rb.headers = copyMap(parent.headers)
 		rb.regexVars = copyRegex(parent.regexVars)
 		rb.cacheConf = parent.cacheConf
+		rb.bodyLimit = parent.bodyLimit
+		rb.maxMemory = parent.maxMemory
 		rb.onError = parent.onError
 		rb.notFound = parent.notFound
+		rb.methodNotAllowed = parent.methodNotAllowed
 		rb.beforeEach = parent.beforeEach
 		rb.afterEach = parent.afterEach
 	} else {
@@ -45,37 +48,43 @@ func NewGroupRouter(app *App, router *router, base string, parent *RouteBuilder)
 
 func (g *GroupRouter) Path(path string) *RouteBuilder {
 	return &RouteBuilder{
-		app:        g.app,
-		router:     g.router,
-		parent:     g.RouteBuilder,
-		path:       g.basePath + path,
-		domain:     g.domain,
-		mws:        append([]Middleware{}, g.mws...),
-		headers:    copyMap(g.headers),
-		regexVars:  copyRegex(g.regexVars),
-		cacheConf:  g.cacheConf,
-		onError:    g.onError,
-		notFound:   g.notFound,
-		beforeEach: g.beforeEach,
-		afterEach:  g.afterEach,
+		app:              g.app,
+		router:           g.router,
+		parent:           g.RouteBuilder,
+		path:             g.basePath + path,
+		domain:           g.domain,
+		mws:              append([]Middleware{}, g.mws...),
+		headers:          copyMap(g.headers),
+		regexVars:        copyRegex(g.regexVars),
+		cacheConf:        g.cacheConf,
+		bodyLimit:        g.bodyLimit,
+		maxMemory:        g.maxMemory,
+		onError:          g.onError,
+		notFound:         g.notFound,
+		methodNotAllowed: g.methodNotAllowed,
+		beforeEach:       g.beforeEach,
+		afterEach:        g.afterEach,
 	}
 }
 
 func (g *GroupRouter) PathPrefix(prefix string) *RouteBuilder {
 	return &RouteBuilder{
-		app:        g.app,
-		router:     g.router,
-		parent:     g.RouteBuilder,
-		prefix:     g.basePath + prefix,
-		domain:     g.domain,
-		mws:        append([]Middleware{}, g.mws...),
-		headers:    copyMap(g.headers),
-		regexVars:  copyRegex(g.regexVars),
-		cacheConf:  g.cacheConf,
-		onError:    g.onError,
-		notFound:   g.notFound,
-		beforeEach: g.beforeEach,
-		afterEach:  g.afterEach,
+		app:              g.app,
+		router:           g.router,
+		parent:           g.RouteBuilder,
+		prefix:           g.basePath + prefix,
+		domain:           g.domain,
+		mws:              append([]Middleware{}, g.mws...),
+		headers:          copyMap(g.headers),
+		regexVars:        copyRegex(g.regexVars),
+		cacheConf:        g.cacheConf,
+		bodyLimit:        g.bodyLimit,
+		maxMemory:        g.maxMemory,
+		onError:          g.onError,
+		notFound:         g.notFound,
+		methodNotAllowed: g.methodNotAllowed,
+		beforeEach:       g.beforeEach,
+		afterEach:        g.afterEach,
 	}
 }
 
@@ -84,19 +93,22 @@ func (g *GroupRouter) PathPrefix(prefix string) *RouteBuilder {
 func (g *GroupRouter) Group(path string, fn ...func(r Router)) *GroupRouter {
 	child := &GroupRouter{
 		RouteBuilder: &RouteBuilder{
-			app:        g.app,
-			router:     g.router,
-			parent:     g.RouteBuilder,
-			path:       g.basePath + path,
-			domain:     g.domain,
-			mws:        append([]Middleware{}, g.mws...),
-			headers:    copyMap(g.headers),
-			regexVars:  copyRegex(g.regexVars),
-			cacheConf:  g.cacheConf,
-			onError:    g.onError,
-			notFound:   g.notFound,
-			beforeEach: g.beforeEach,
-			afterEach:  g.afterEach,
+			app:              g.app,
+			router:           g.router,
+			parent:           g.RouteBuilder,
+			path:             g.basePath + path,
+			domain:           g.domain,
+			mws:              append([]Middleware{}, g.mws...),
+			headers:          copyMap(g.headers),
+			regexVars:        copyRegex(g.regexVars),
+			cacheConf:        g.cacheConf,
+			bodyLimit:        g.bodyLimit,
+			maxMemory:        g.maxMemory,
+			onError:          g.onError,
+			notFound:         g.notFound,
+			methodNotAllowed: g.methodNotAllowed,
+			beforeEach:       g.beforeEach,
+			afterEach:        g.afterEach,
 		},
 		basePath: g.basePath + path,
 	}
@@ -109,19 +121,22 @@ func (g *GroupRouter) Group(path string, fn ...func(r Router)) *GroupRouter {
 func (g *GroupRouter) PathPrefixGroup(prefix string, fn ...func(r Router)) *GroupRouter {
 	child := &GroupRouter{
 		RouteBuilder: &RouteBuilder{
-			app:        g.app,
-			router:     g.router,
-			parent:     g.RouteBuilder,
-			prefix:     g.basePath + prefix,
-			domain:     g.domain,
-			mws:        append([]Middleware{}, g.mws...),
-			headers:    copyMap(g.headers),
-			regexVars:  copyRegex(g.regexVars),
-			cacheConf:  g.cacheConf,
-			onError:    g.onError,
-			notFound:   g.notFound,
-			beforeEach: g.beforeEach,
-			afterEach:  g.afterEach,
+			app:              g.app,
+			router:           g.router,
+			parent:           g.RouteBuilder,
+			prefix:           g.basePath + prefix,
+			domain:           g.domain,
+			mws:              append([]Middleware{}, g.mws...),
+			headers:          copyMap(g.headers),
+			regexVars:        copyRegex(g.regexVars),
+			cacheConf:        g.cacheConf,
+			bodyLimit:        g.bodyLimit,
+			maxMemory:        g.maxMemory,
+			onError:          g.onError,
+			notFound:         g.notFound,
+			methodNotAllowed: g.methodNotAllowed,
+			beforeEach:       g.beforeEach,
+			afterEach:        g.afterEach,
 		},
 		basePath: g.basePath + prefix,
 	}
@@ -167,6 +182,12 @@ func (g *GroupRouter) Cache(duration time.Duration) *GroupRouter {
 	return g
 }
 
+// BodyLimit limita el cuerpo de las peticiones de las rutas del grupo.
+func (g *GroupRouter) BodyLimit(max int64, opts ...BodyLimitOption) *GroupRouter {
+	g.bodyLimit = newBodyLimit(max, opts)
+	return g
+}
+
 // ========== HOOKS Y HANDLERS DE ERROR/NOTFOUND ==========
 
 func (g *GroupRouter) OnError(fn func(ctx *Context, err error)) *GroupRouter {
@@ -177,6 +198,10 @@ func (g *GroupRouter) NotFound(fn func(ctx *Context)) *GroupRouter {
 	g.notFound = fn
 	return g
 }
+func (g *GroupRouter) MethodNotAllowed(fn func(ctx *Context)) *GroupRouter {
+	g.methodNotAllowed = fn
+	return g
+}
 func (g *GroupRouter) BeforeEach(fn func(ctx *Context)) *GroupRouter {
 	g.beforeEach = fn
 	return g
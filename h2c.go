@@ -0,0 +1,273 @@
+//go:build go1.24
+
+package ki
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// enableH2C acepta HTTP/2 sin TLS con prior knowledge, además de HTTP/1. El
+// Upgrade desde HTTP/1.1 lo resuelve h2cUpgrader sobre el mismo servidor.
+func enableH2C(srv *http.Server) error {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	srv.Protocols = &p
+	srv.Handler = &h2cUpgrader{next: srv.Handler, srv: srv}
+	return nil
+}
+
+// applyHTTP2 pasa los ajustes de SetHTTP2* a srv.HTTP2.
+func applyHTTP2(srv *http.Server, o http2Options) error {
+	if o == (http2Options{}) {
+		return nil
+	}
+	if srv.HTTP2 == nil {
+		srv.HTTP2 = &http.HTTP2Config{}
+	}
+	if o.maxStreams > 0 {
+		srv.HTTP2.MaxConcurrentStreams = o.maxStreams
+	}
+	if o.pingTimeout > 0 {
+		srv.HTTP2.SendPingTimeout = o.pingTimeout
+	}
+	return nil
+}
+
+// ----------- UPGRADE h2c (RFC 7540 §3.2) -----------
+
+const (
+	h2cPreface        = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	h2cMaxFrame       = 16384 // SETTINGS_MAX_FRAME_SIZE inicial
+	h2cPrefaceTimeout = 10 * time.Second
+
+	frameHeaders  = 0x1
+	frameSettings = 0x4
+	flagEndStream = 0x1
+	flagAck       = 0x1
+	flagEndHeader = 0x4
+)
+
+// h2cUpgrader atiende "Upgrade: h2c": responde 101 y entrega la conexión al
+// servidor como si el cliente hubiera usado prior knowledge, con la petición
+// original como stream 1. Las peticiones con cuerpo siguen en HTTP/1.1
+// (el RFC permite ignorar el Upgrade).
+type h2cUpgrader struct {
+	next http.Handler
+	srv  *http.Server
+	once sync.Once
+	ln   *connListener
+}
+
+func (u *h2cUpgrader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	settings, ok := h2cUpgradeSettings(r)
+	if !ok {
+		u.next.ServeHTTP(w, r)
+		return
+	}
+	block := h2cHeaderBlock(r)
+	if len(block) > h2cMaxFrame {
+		u.next.ServeHTTP(w, r)
+		return
+	}
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		u.next.ServeHTTP(w, r)
+		return
+	}
+	conn.SetDeadline(time.Now().Add(h2cPrefaceTimeout))
+	if _, err := io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+	// El cliente responde con el preface y su SETTINGS; los del header
+	// HTTP2-Settings van delante para que los suyos tengan prioridad.
+	clientSettings, err := readClientSettings(brw.Reader)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	var prefix []byte
+	prefix = append(prefix, h2cPreface...)
+	prefix = appendFrame(prefix, frameSettings, 0, 0, append(settings, clientSettings...))
+	prefix = appendFrame(prefix, frameHeaders, flagEndStream|flagEndHeader, 1, block)
+
+	u.once.Do(func() {
+		u.ln = newConnListener(conn.LocalAddr())
+		go func() {
+			u.srv.Serve(u.ln)
+			u.ln.Close()
+		}()
+	})
+	upgraded := &h2cConn{Conn: conn, r: io.MultiReader(bytes.NewReader(prefix), brw.Reader)}
+	if !u.ln.push(upgraded) {
+		conn.Close()
+	}
+}
+
+// h2cUpgradeSettings valida la petición de Upgrade y devuelve el payload de
+// HTTP2-Settings.
+func h2cUpgradeSettings(r *http.Request) ([]byte, bool) {
+	if r.ProtoMajor != 1 || r.TLS != nil || r.Body != http.NoBody ||
+		!hasToken(r.Header["Upgrade"], "h2c") ||
+		!hasToken(r.Header["Connection"], "upgrade") || !hasToken(r.Header["Connection"], "http2-settings") {
+		return nil, false
+	}
+	values := r.Header["Http2-Settings"]
+	if len(values) != 1 {
+		return nil, false
+	}
+	settings, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil || len(settings)%6 != 0 {
+		return nil, false
+	}
+	return settings, true
+}
+
+func hasToken(values []string, token string) bool {
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readClientSettings lee el preface del cliente y el payload de su primer
+// frame, que debe ser SETTINGS.
+func readClientSettings(r io.Reader) ([]byte, error) {
+	buf := make([]byte, len(h2cPreface)+9)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if string(buf[:len(h2cPreface)]) != h2cPreface {
+		return nil, errors.New("ki: h2c sin preface del cliente")
+	}
+	hdr := buf[len(h2cPreface):]
+	n := int(hdr[0])<<16 | int(hdr[1])<<8 | int(hdr[2])
+	if hdr[3] != frameSettings || hdr[4]&flagAck != 0 || n > h2cMaxFrame || n%6 != 0 {
+		return nil, errors.New("ki: h2c sin SETTINGS del cliente")
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// h2cHopHeaders no pasan a HTTP/2 (RFC 9113 §8.2.2).
+var h2cHopHeaders = map[string]bool{
+	"connection": true, "upgrade": true, "http2-settings": true, "keep-alive": true,
+	"proxy-connection": true, "transfer-encoding": true, "host": true,
+}
+
+// h2cHeaderBlock codifica la petición original en HPACK con literales sin
+// indexar, que el decodificador acepta sin estado previo.
+func h2cHeaderBlock(r *http.Request) []byte {
+	var b []byte
+	b = hpackField(b, ":method", r.Method)
+	b = hpackField(b, ":scheme", "http")
+	b = hpackField(b, ":authority", r.Host)
+	b = hpackField(b, ":path", r.URL.RequestURI())
+	for k, values := range r.Header {
+		name := strings.ToLower(k)
+		if h2cHopHeaders[name] || hasToken(r.Header["Connection"], name) {
+			continue
+		}
+		for _, v := range values {
+			if name == "te" && v != "trailers" {
+				continue
+			}
+			b = hpackField(b, name, v)
+		}
+	}
+	return b
+}
+
+func hpackField(b []byte, name, value string) []byte {
+	b = append(b, 0) // literal sin indexar, nombre nuevo
+	b = hpackString(b, name)
+	return hpackString(b, value)
+}
+
+// hpackString escribe s sin Huffman, con el largo en un entero de prefijo 7.
+func hpackString(b []byte, s string) []byte {
+	n := uint64(len(s))
+	if n < 127 {
+		b = append(b, byte(n))
+	} else {
+		b = append(b, 127)
+		for n -= 127; n >= 128; n >>= 7 {
+			b = append(b, byte(n)|0x80)
+		}
+		b = append(b, byte(n))
+	}
+	return append(b, s...)
+}
+
+func appendFrame(b []byte, typ, flags byte, stream uint32, payload []byte) []byte {
+	n := len(payload)
+	b = append(b, byte(n>>16), byte(n>>8), byte(n), typ, flags,
+		byte(stream>>24), byte(stream>>16), byte(stream>>8), byte(stream))
+	return append(b, payload...)
+}
+
+// h2cConn lee primero los bytes reconstruidos y luego el resto de la conexión.
+type h2cConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *h2cConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connListener entrega al http.Server las conexiones ya aceptadas por Upgrade.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *connListener) push(c net.Conn) bool {
+	select {
+	case l.conns <- c:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
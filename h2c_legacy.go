@@ -0,0 +1,22 @@
+//go:build !go1.24
+
+package ki
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Antes de Go 1.24 net/http no sirve HTTP/2 sin TLS; no se usa golang.org/x/net
+// para no agregar dependencias.
+func enableH2C(*http.Server) error {
+	return errors.New("ki: h2c requiere compilar con Go 1.24 o superior")
+}
+
+// Sin http.HTTP2Config los ajustes de SetHTTP2* no se pueden aplicar.
+func applyHTTP2(_ *http.Server, o http2Options) error {
+	if o == (http2Options{}) {
+		return nil
+	}
+	return errors.New("ki: los ajustes de HTTP/2 requieren compilar con Go 1.24 o superior")
+}
@@ -0,0 +1,155 @@
+package ki
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// In marca un struct como entrada de un handler: al declararlo como parámetro
+// ki lo llena con BindAndValidate antes de invocar al handler.
+//
+//	type CreateUserInput struct {
+//		ki.In
+//		Name string `json:"name" validate:"required"`
+//	}
+//
+//	app.Post("/users", func(in CreateUserInput, svc *UserService) (*User, error) {
+//		return svc.Create(in.Name)
+//	})
+//
+// El valor devuelto (que no sea error) se renderiza como JSON con status 200,
+// o con el de StatusCode() si lo implementa; un puntero nil responde 204 y un
+// slice o mapa nil se renderiza vacío ([] o {}).
+type In struct{}
+
+func (In) kiInput() {}
+
+type input interface {
+	kiInput()
+}
+
+// StatusCoder permite que el valor devuelto por un handler elija el status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// ResultRenderer escribe el valor devuelto por un handler.
+type ResultRenderer func(ctx *Context, code int, v any) error
+
+// RenderResults reemplaza el renderer por defecto (JSON) de los valores
+// devueltos por los handlers.
+func (app *App) RenderResults(fn ResultRenderer) {
+	app.resultRenderer = fn
+}
+
+var (
+	inputType = reflect.TypeOf((*input)(nil)).Elem()
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// handlerSig es lo que invokeHandler necesita saber de la firma del handler.
+type handlerSig struct {
+	inputs []reflect.Type // parámetros que embeben ki.In (T o *T)
+	result int            // índice del valor a renderizar, -1 si no hay
+	err    int            // índice del error, -1 si no hay
+}
+
+var handlerSigs sync.Map // reflect.Type -> *handlerSig
+
+func signatureOf(t reflect.Type) *handlerSig {
+	if sig, ok := handlerSigs.Load(t); ok {
+		return sig.(*handlerSig)
+	}
+	sig := &handlerSig{result: -1, err: -1}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if in.Implements(inputType) && indirectType(in).Kind() == reflect.Struct {
+			sig.inputs = append(sig.inputs, in)
+		}
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		switch {
+		case t.Out(i) == errorType:
+			if sig.err < 0 {
+				sig.err = i
+			}
+		case sig.result < 0:
+			sig.result = i
+		}
+	}
+	handlerSigs.Store(t, sig)
+	return sig
+}
+
+// invokeHandler resuelve el handler con el inyector: llena los parámetros ki.In,
+// lo invoca y renderiza el valor devuelto.
+func invokeHandler(ctx *Context, h HandlerFunc) error {
+	t := reflect.TypeOf(h)
+	if t == nil || t.Kind() != reflect.Func {
+		return ctx.injector.InvokeWithErrorOnly(h)
+	}
+	sig := signatureOf(t)
+	for _, in := range sig.inputs {
+		ptr := reflect.New(indirectType(in))
+		if err := ctx.BindAndValidate(ptr.Interface()); err != nil {
+			return err
+		}
+		if in.Kind() == reflect.Ptr {
+			ctx.injector.Set(in, ptr)
+		} else {
+			ctx.injector.Set(in, ptr.Elem())
+		}
+	}
+	if sig.result < 0 {
+		return ctx.injector.InvokeWithErrorOnly(h)
+	}
+	out, err := ctx.injector.Invoke(h)
+	if err != nil {
+		return err
+	}
+	if sig.err >= 0 {
+		if err, _ := out[sig.err].Interface().(error); err != nil {
+			return err
+		}
+	}
+	return renderResult(ctx, out[sig.result])
+}
+
+func renderResult(ctx *Context, v reflect.Value) error {
+	switch {
+	case isNilValue(v):
+		ctx.Writer.WriteHeader(http.StatusNoContent)
+		return nil
+	// Una lista o un mapa vacío es un resultado: se responde [] o {}, no null
+	case v.Kind() == reflect.Slice && v.IsNil():
+		v = reflect.MakeSlice(v.Type(), 0, 0)
+	case v.Kind() == reflect.Map && v.IsNil():
+		v = reflect.MakeMap(v.Type())
+	}
+	body := v.Interface()
+	code := http.StatusOK
+	if sc, ok := body.(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	if ctx.App != nil && ctx.App.resultRenderer != nil {
+		return ctx.App.resultRenderer(ctx, code, body)
+	}
+	return ctx.JSON(code, body)
+}
+
+// isNilValue indica un resultado ausente (204): puntero o interface nil.
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
@@ -5,9 +5,13 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"maps"
+	"net"
 	"net/http"
 	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jad21/di"
@@ -38,10 +42,59 @@ type App struct {
 	DI             di.Injector
 
 	// Nuevos handlers globales
-	onError  func(ctx *Context, err error)
-	notFound func(ctx *Context)
-	before   func(ctx *Context)
-	after    func(ctx *Context)
+	onError    func(ctx *Context, err error)
+	notFound   func(ctx *Context)
+	notAllowed func(ctx *Context)
+	before     func(ctx *Context)
+	after      func(ctx *Context)
+
+	// Traducción de errores de dominio a HTTPError
+	errorMappers []ErrorMapper
+	problems     *ProblemOptions // errores como problem+json (UseProblemDetails)
+
+	// Respuestas automáticas del router
+	autoHead    bool
+	autoOptions bool
+
+	// Detección de rutas duplicadas/ambiguas
+	strictRoutes RouteConflictMode
+	routeErrs    []error
+
+	// Converters de parámetros ({id:int})
+	converters map[string]*Converter
+
+	// Reglas del tag validate
+	validators map[string]ValidationRule
+
+	// Renderer de los valores devueltos por los handlers (JSON por defecto)
+	resultRenderer ResultRenderer
+	// Renderers de ctx.Respond por media type
+	renderers []mediaRenderer
+
+	// Límite del cuerpo para las rutas sin BodyLimit propio
+	bodyLimit *bodyLimit
+
+	// Ciclo de vida (Run/Shutdown); stop cancela App.Context al apagar
+	stop          context.CancelFunc
+	onStart       []LifecycleHook
+	onShutdown    []LifecycleHook
+	ready         atomic.Bool
+	servers       []*http.Server
+	serversMu     sync.Mutex
+	shutdownOnce  sync.Once
+	shutdownErr   error
+	drainTimeout  time.Duration
+	shutdownDelay time.Duration
+	hookTimeout   time.Duration
+
+	// Ajustes del http.Server (ver newServer)
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	maxHeaderBytes    int
+	h2c               bool
+	http2             http2Options
+	connState         []func(net.Conn, http.ConnState)
+	serverConfig      func(srv *http.Server)
 }
 
 // TemplateEngine define la interfaz para un motor de plantillas.
@@ -65,12 +118,38 @@ type options struct {
 	WriteTimeout   time.Duration
 	ReadTimeout    time.Duration
 	TemplateEngine TemplateEngine
+	AutoHead       bool
+	AutoOptions    bool
+	StrictRoutes   RouteConflictMode
+	BodyLimit      *bodyLimit
+	DrainTimeout   time.Duration
+	ShutdownDelay  time.Duration
+	HookTimeout    time.Duration
+
+	// Ajustes del http.Server
+	IdleTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	MaxHeaderBytes    int
+	H2C               bool
+	HTTP2             http2Options
+	ServerConfig      func(srv *http.Server)
+}
+
+// http2Options son los ajustes de srv.HTTP2 (ver SetHTTP2*).
+type http2Options struct {
+	maxStreams  int
+	pingTimeout time.Duration
 }
 type Option func(o *options)
 
 var defaultOptions = options{
 	WriteTimeout: 60 * time.Second,
 	ReadTimeout:  60 * time.Second,
+	AutoHead:     true,
+	AutoOptions:  true,
+	StrictRoutes: RoutesLog,
+	DrainTimeout: 30 * time.Second,
+	HookTimeout:  10 * time.Second,
 }
 
 func New(opt ...Option) *App {
@@ -88,14 +167,36 @@ func New(opt ...Option) *App {
 		o(&opts)
 	}
 
+	base, stop := context.WithCancel(context.Background())
 	app := &App{
 		DI:             di.New(),
-		Context:        context.Background(),
+		Context:        base,
+		stop:           stop,
 		WriteTimeout:   opts.WriteTimeout,
 		ReadTimeout:    opts.ReadTimeout,
 		TemplateEngine: opts.TemplateEngine,
+		autoHead:       opts.AutoHead,
+		autoOptions:    opts.AutoOptions,
+		strictRoutes:   opts.StrictRoutes,
+		converters:     copyConverters(defaultConverters),
+		validators:     maps.Clone(defaultValidators),
+		renderers:      slices.Clone(defaultRenderers),
+		bodyLimit:      opts.BodyLimit,
+		drainTimeout:   opts.DrainTimeout,
+		shutdownDelay:  opts.ShutdownDelay,
+		hookTimeout:    opts.HookTimeout,
+
+		idleTimeout:       opts.IdleTimeout,
+		readHeaderTimeout: opts.ReadHeaderTimeout,
+		maxHeaderBytes:    opts.MaxHeaderBytes,
+		h2c:               opts.H2C,
+		http2:             opts.HTTP2,
+		serverConfig:      opts.ServerConfig,
 	}
 	app.Router = NewRoute(app)
+	if reg, ok := app.TemplateEngine.(urlResolverSetter); ok {
+		reg.SetURLResolver(app.URL)
+	}
 	app.pool.New = func() interface{} {
 		return NewContext(app.Context, app, nil, nil)
 	}
@@ -122,6 +223,118 @@ func SetReadTimeout(t time.Duration) Option {
 	}
 }
 
+// SetAutoHead activa/desactiva responder HEAD con las rutas GET (sin cuerpo).
+func SetAutoHead(enabled bool) Option {
+	return func(o *options) {
+		o.AutoHead = enabled
+	}
+}
+
+// SetAutoOptions activa/desactiva la respuesta automática a OPTIONS con el header Allow.
+func SetAutoOptions(enabled bool) Option {
+	return func(o *options) {
+		o.AutoOptions = enabled
+	}
+}
+
+// SetBodyLimit limita el cuerpo de todas las peticiones; los grupos y rutas
+// pueden reemplazarlo con BodyLimit (ver ki.BodyLimit).
+func SetBodyLimit(max int64, opts ...BodyLimitOption) Option {
+	return func(o *options) {
+		o.BodyLimit = newBodyLimit(max, opts)
+	}
+}
+
+// SetDrainTimeout es cuánto espera Run a que terminen las peticiones en curso
+// al apagarse (por defecto 30s); después corta las conexiones.
+func SetDrainTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.DrainTimeout = d
+	}
+}
+
+// SetShutdownHookTimeout es el tiempo que tienen los hooks OnShutdown, en
+// conjunto, para terminar (por defecto 10s). Corre aparte del drenado: aunque
+// éste se venza, los hooks reciben un contexto vigente.
+func SetShutdownHookTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.HookTimeout = d
+	}
+}
+
+// SetShutdownDelay es cuánto espera Shutdown, con Ready en false, antes de
+// dejar de aceptar conexiones (útil para que Kubernetes saque el pod del Service).
+func SetShutdownDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.ShutdownDelay = d
+	}
+}
+
+// SetIdleTimeout es cuánto se mantiene abierta una conexión keep-alive sin
+// peticiones (0 = ReadTimeout, como en net/http).
+func SetIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.IdleTimeout = d
+	}
+}
+
+// SetReadHeaderTimeout limita el tiempo para leer los headers de la petición
+// (0 = ReadTimeout, como en net/http).
+func SetReadHeaderTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.ReadHeaderTimeout = d
+	}
+}
+
+// SetMaxHeaderBytes limita el tamaño de los headers de la petición (0 = 1 MB).
+func SetMaxHeaderBytes(n int) Option {
+	return func(o *options) {
+		o.MaxHeaderBytes = n
+	}
+}
+
+// SetH2C acepta HTTP/2 sin TLS en los endpoints sin TLS, por ejemplo detrás de
+// un balanceador interno: con prior knowledge y con "Upgrade: h2c" desde
+// HTTP/1.1 (las peticiones con cuerpo que piden Upgrade siguen en HTTP/1.1).
+// Requiere Go 1.24.
+func SetH2C(enabled bool) Option {
+	return func(o *options) {
+		o.H2C = enabled
+	}
+}
+
+// SetHTTP2MaxConcurrentStreams limita los streams simultáneos por conexión
+// HTTP/2 (0 = 100 por defecto de net/http). Requiere Go 1.24.
+func SetHTTP2MaxConcurrentStreams(n int) Option {
+	return func(o *options) {
+		o.HTTP2.maxStreams = n
+	}
+}
+
+// SetHTTP2SendPingTimeout envía un PING cuando una conexión HTTP/2 no recibe
+// frames durante d, para detectar clientes caídos. Requiere Go 1.24.
+func SetHTTP2SendPingTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.HTTP2.pingTimeout = d
+	}
+}
+
+// SetServerConfig permite ajustar cada http.Server antes de servir. Se llama
+// al final, así puede pisar lo anterior; por ejemplo el resto de srv.HTTP2:
+//
+//	ki.SetServerConfig(func(srv *http.Server) {
+//		if srv.HTTP2 == nil {
+//			srv.HTTP2 = &http.HTTP2Config{} // Go 1.24+
+//		}
+//		srv.HTTP2.MaxReadFrameSize = 1 << 20
+//		srv.ErrorLog = log.New(logFile, "http: ", 0)
+//	})
+func SetServerConfig(fn func(srv *http.Server)) Option {
+	return func(o *options) {
+		o.ServerConfig = fn
+	}
+}
+
 // Inyectar variable inicializada
 func (s *App) Inject(v interface{}, o ...di.Option) reflect.Type {
 	return s.DI.Map(v, o...)
@@ -239,18 +452,11 @@ func (s *App) StaticFS(path string, fsys fs.FS) {
 // ----------------------------------------------
 // Server
 // ----------------------------------------------
-func (s *App) ListenAndServe() {
-	port := env.GetEnvVar("PORT", "5000")
-	log.Printf("go to http://localhost:%s", port)
-
-	handler := LoggingHandler(s.Router)
-	handler = ProxyHeaders(handler)
 
-	srv := &http.Server{
-		Handler:      handler,
-		Addr:         ":" + port,
-		WriteTimeout: s.WriteTimeout,
-		ReadTimeout:  s.ReadTimeout,
+// ListenAndServe es Run sin contexto: escucha en :PORT y se apaga de forma
+// ordenada con SIGINT/SIGTERM.
+func (s *App) ListenAndServe() {
+	if err := s.Run(context.Background()); err != nil {
+		log.Fatal(err)
 	}
-	log.Fatal(srv.ListenAndServe())
 }
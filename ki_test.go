@@ -1,17 +1,42 @@
 package ki
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"log"
+	"math/big"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
+
+	"github.com/jad21/ki/templates"
 )
 
 // Servicio simulado para DI
@@ -418,6 +443,32 @@ func TestRouteBuilder_Cache(t *testing.T) {
 	}
 }
 
+func TestRouteBuilder_CacheError(t *testing.T) {
+	app := New()
+	app.Use(RefreshSessionMiddleware)
+	calls := 0
+	app.Path("/flaky").Cache(time.Minute).Handle(func(ctx *Context) error {
+		calls++
+		if calls == 1 {
+			return NotFoundError("todavía no")
+		}
+		ctx.Text(200, "listo")
+		return nil
+	})
+
+	// El error no se cachea y el siguiente pedido vuelve al handler
+	for _, want := range []int{404, 200, 200} {
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, httptest.NewRequest("GET", "/flaky", nil))
+		if w.Code != want {
+			t.Fatalf("got %d %q, want %d", w.Code, w.Body, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
 func TestGroup_Static(t *testing.T) {
 	dir := t.TempDir()
 	err := os.WriteFile(dir+"/gfile.txt", []byte("grupo!"), 0644)
@@ -527,3 +578,2401 @@ func TestApp_StaticFS_Embed(t *testing.T) {
 		t.Error("El middleware global no se ejecutó al servir el archivo embebido")
 	}
 }
+
+func TestRouter_Priority(t *testing.T) {
+	app := New()
+	app.PathPrefix("/items/").Handle(func(ctx *Context) {
+		ctx.Text(200, "prefix")
+	})
+	app.Get("/items/:name", func(ctx *Context) {
+		ctx.Text(200, "param:"+ctx.Vars()["name"])
+	})
+	app.Get("/items/:id([0-9]+)", func(ctx *Context) {
+		ctx.Text(200, "regex:"+ctx.Vars()["id"])
+	})
+	app.Get("/items/new", func(ctx *Context) {
+		ctx.Text(200, "static")
+	})
+	app.Path("/items/new").Method("GET").Headers("X-Version", "2").Handle(func(ctx *Context) {
+		ctx.Text(200, "static-v2")
+	})
+	app.Domain("admin.local").Path("/items/new").Method("GET").Handle(func(ctx *Context) {
+		ctx.Text(200, "static-admin")
+	})
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	cases := []struct {
+		path    string
+		host    string
+		headers map[string]string
+		want    string
+	}{
+		{path: "/items/new", want: "static"},
+		{path: "/items/new", headers: map[string]string{"X-Version": "2"}, want: "static-v2"},
+		{path: "/items/new", host: "admin.local", want: "static-admin"},
+		{path: "/items/42", want: "regex:42"},
+		{path: "/items/abc", want: "param:abc"},
+		{path: "/items/abc/def", want: "prefix"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", server.URL+c.path, nil)
+		if c.host != "" {
+			req.Host = c.host
+		}
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assertStatus(t, resp, 200)
+		assertBody(t, string(body), c.want)
+	}
+}
+
+func TestRouter_LongestPrefix(t *testing.T) {
+	app := New()
+	app.PathPrefix("/a/").Handle(func(ctx *Context) { ctx.Text(200, "a") })
+	app.PathPrefix("/a/b/").Handle(func(ctx *Context) { ctx.Text(200, "ab") })
+	app.PathPrefix("/ab").Handle(func(ctx *Context) { ctx.Text(200, "ab-char") })
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/a/x":    "a",
+		"/a/b/x":  "ab",
+		"/abc":    "ab-char",
+		"/a/bcde": "a",
+	} {
+		resp, body := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 200)
+		assertBody(t, body, want)
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", func(ctx *Context) { ctx.Text(200, "get") })
+	app.Put("/users/:id", func(ctx *Context) { ctx.Text(200, "put") })
+	app.Delete("/users/:id", func(ctx *Context) { ctx.Text(200, "delete") })
+
+	api := app.Group("/api")
+	api.MethodNotAllowed(func(ctx *Context) {
+		ctx.Text(405, "api-not-allowed")
+	})
+	api.Post("/items", func(ctx *Context) { ctx.Text(201, "created") })
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	t.Run("Default 405 with Allow", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/users/42", "text/plain", nil)
+		if err != nil {
+			t.Fatalf("http.Post failed: %v", err)
+		}
+		resp.Body.Close()
+		assertStatus(t, resp, http.StatusMethodNotAllowed)
+		if got := resp.Header.Get("Allow"); got != "DELETE, GET, HEAD, OPTIONS, PUT" {
+			t.Fatalf("Expected Allow %q, got %q", "DELETE, GET, HEAD, OPTIONS, PUT", got)
+		}
+	})
+
+	t.Run("Scoped handler", func(t *testing.T) {
+		resp, body := httpGet(t, server.URL+"/api/items")
+		assertStatus(t, resp, http.StatusMethodNotAllowed)
+		assertBody(t, body, "api-not-allowed")
+		if got := resp.Header.Get("Allow"); got != "OPTIONS, POST" {
+			t.Fatalf("Expected Allow %q, got %q", "OPTIONS, POST", got)
+		}
+	})
+
+	t.Run("App handler", func(t *testing.T) {
+		app.MethodNotAllowed(func(ctx *Context) {
+			ctx.Text(405, "app-not-allowed")
+		})
+		resp, err := http.Post(server.URL+"/users/42", "text/plain", nil)
+		if err != nil {
+			t.Fatalf("http.Post failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assertStatus(t, resp, http.StatusMethodNotAllowed)
+		assertBody(t, string(body), "app-not-allowed")
+	})
+
+	t.Run("Unknown path is still 404", func(t *testing.T) {
+		resp, _ := httpGet(t, server.URL+"/nope")
+		assertStatus(t, resp, http.StatusNotFound)
+	})
+}
+
+func TestRouter_AutoHeadOptions(t *testing.T) {
+	app := New()
+	app.Get("/doc", func(ctx *Context) {
+		ctx.SetHeader("X-Doc", "1")
+		ctx.Text(200, "document body")
+	})
+	app.Post("/doc", func(ctx *Context) { ctx.Text(201, "created") })
+	app.Get("/custom", func(ctx *Context) { ctx.Text(200, "custom") })
+	app.Options("/custom", func(ctx *Context) { ctx.Text(200, "explicit-options") })
+	app.Get("/events", func(ctx *Context) error {
+		sse, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		return sse.Data("hola")
+	})
+	app.Get("/stream", func(ctx *Context) error {
+		return ctx.Stream(func(w io.Writer) bool {
+			io.WriteString(w, "tick")
+			return false
+		})
+	})
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	t.Run("HEAD uses GET route", func(t *testing.T) {
+		resp, err := http.Head(server.URL + "/doc")
+		if err != nil {
+			t.Fatalf("http.Head failed: %v", err)
+		}
+		resp.Body.Close()
+		assertStatus(t, resp, 200)
+		if resp.Header.Get("X-Doc") != "1" {
+			t.Fatal("Expected headers from GET handler")
+		}
+		if resp.ContentLength != int64(len("document body")) {
+			t.Fatalf("Expected Content-Length %d, got %d", len("document body"), resp.ContentLength)
+		}
+	})
+
+	t.Run("HEAD discards body", func(t *testing.T) {
+		req := httptest.NewRequest("HEAD", "/doc", nil)
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		if w.Body.Len() != 0 {
+			t.Fatalf("Expected empty body, got %q", w.Body.String())
+		}
+		if w.Header().Get("Content-Length") != strconv.Itoa(len("document body")) {
+			t.Fatalf("Unexpected Content-Length %q", w.Header().Get("Content-Length"))
+		}
+	})
+
+	t.Run("HEAD on SSE and stream routes", func(t *testing.T) {
+		for path, ct := range map[string]string{"/events": "text/event-stream", "/stream": ""} {
+			resp, err := http.Head(server.URL + path)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			resp.Body.Close()
+			assertStatus(t, resp, 200)
+			if ct != "" && resp.Header.Get("Content-Type") != ct {
+				t.Errorf("%s: Content-Type %q", path, resp.Header.Get("Content-Type"))
+			}
+		}
+	})
+
+	t.Run("Automatic OPTIONS", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", server.URL+"/doc", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		assertStatus(t, resp, http.StatusNoContent)
+		if got := resp.Header.Get("Allow"); got != "GET, HEAD, OPTIONS, POST" {
+			t.Fatalf("Expected Allow %q, got %q", "GET, HEAD, OPTIONS, POST", got)
+		}
+	})
+
+	t.Run("Explicit OPTIONS wins", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", server.URL+"/custom", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assertStatus(t, resp, 200)
+		assertBody(t, string(body), "explicit-options")
+	})
+
+	t.Run("Disabled by option", func(t *testing.T) {
+		app := New(SetAutoHead(false), SetAutoOptions(false))
+		app.Get("/doc", func(ctx *Context) { ctx.Text(200, "doc") })
+		for _, method := range []string{"HEAD", "OPTIONS"} {
+			w := httptest.NewRecorder()
+			app.Router.ServeHTTP(w, httptest.NewRequest(method, "/doc", nil))
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("%s: expected 405, got %d", method, w.Code)
+			}
+			if got := w.Header().Get("Allow"); got != "GET" {
+				t.Fatalf("%s: expected Allow %q, got %q", method, "GET", got)
+			}
+		}
+	})
+}
+
+func TestApp_URL(t *testing.T) {
+	reg, err := templates.New(templates.DirFS(fstest.MapFS{
+		"link.html": {Data: []byte(`<a href="{{ url "user.show" "id" .ID }}">ver</a>`)},
+	}), templates.Suffix(".html"))
+	if err != nil {
+		t.Fatalf("templates.New failed: %v", err)
+	}
+	app := New(SetTemplateEngine(reg))
+	h := func(ctx *Context) {}
+	app.Get("/user/:id([0-9]+)", h).Name("user.show")
+	app.Path("/posts/{slug}").Name("post.show").Method("GET").Handle(h)
+	app.Group("/admin").Get("/", h).Name("admin.home")
+
+	cases := []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{"user.show", []string{"id", "42"}, "/user/42"},
+		{"user.show", []string{"id", "42", "tab", "posts"}, "/user/42?tab=posts"},
+		{"post.show", []string{"slug", "hola mundo"}, "/posts/hola%20mundo"},
+		{"admin.home", nil, "/admin/"},
+	}
+	for _, c := range cases {
+		got, err := app.URL(c.name, c.params...)
+		if err != nil {
+			t.Fatalf("URL(%q) failed: %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("URL(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	for _, bad := range [][]string{
+		{"user.show", "id", "abc123"},
+		{"user.show"},
+		{"user.show", "id"},
+		{"missing.route"},
+	} {
+		if _, err := app.URL(bad[0], bad[1:]...); err == nil {
+			t.Errorf("URL(%q) expected error", bad)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := app.TemplateEngine.ExecuteTemplate(&buf, "link.html", M{"ID": 7}); err != nil {
+		t.Fatalf("ExecuteTemplate failed: %v", err)
+	}
+	assertBody(t, buf.String(), `<a href="/user/7">ver</a>`)
+
+	t.Run("Duplicate name panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected panic for duplicate route name")
+			}
+		}()
+		app.Get("/other", h).Name("user.show")
+	})
+}
+
+func routesTestHandler(ctx *Context)    {}
+func routesTestMiddleware(ctx *Context) { ctx.Next() }
+
+func TestApp_Routes(t *testing.T) {
+	app := New()
+	app.Use(routesTestMiddleware)
+	app.Get("/user/:id([0-9]+)", routesTestHandler).Name("user.show")
+	api := app.Group("/api")
+	api.Domain("api.local").Headers("X-Version", "2").Cache(time.Minute)
+	api.Get("/items/{slug}", routesTestHandler).RegexVar("slug", "[a-z]+")
+	app.Static("/static/", t.TempDir())
+
+	routes := app.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("Expected 3 routes, got %d", len(routes))
+	}
+
+	user := routes[0]
+	if user.Method != "GET" || user.Pattern != "/user/:id([0-9]+)" || user.Name != "user.show" {
+		t.Errorf("Unexpected user route: %+v", user)
+	}
+	if user.RegexVars["id"] != "[0-9]+" {
+		t.Errorf("Expected regex var id, got %v", user.RegexVars)
+	}
+	if user.Handler != "ki.routesTestHandler" {
+		t.Errorf("Expected handler name ki.routesTestHandler, got %q", user.Handler)
+	}
+	if len(user.Middlewares) != 1 || user.Middlewares[0] != "ki.routesTestMiddleware" {
+		t.Errorf("Unexpected middlewares: %v", user.Middlewares)
+	}
+
+	items := routes[1]
+	if items.Pattern != "/api/items/{slug}" || items.Domain != "api.local" || items.Headers["X-Version"] != "2" {
+		t.Errorf("Unexpected group route: %+v", items)
+	}
+	if items.Cache != time.Minute || len(items.Middlewares) != 2 {
+		t.Errorf("Expected cache policy and cache middleware, got %+v", items)
+	}
+
+	static := routes[2]
+	if !static.Prefix || static.Method != "" || static.Pattern != "/static/" {
+		t.Errorf("Unexpected static route: %+v", static)
+	}
+
+	var buf bytes.Buffer
+	if err := app.PrintRoutes(&buf); err != nil {
+		t.Fatalf("PrintRoutes failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected header + 3 lines, got:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "METHOD") || !strings.Contains(lines[1], "user.show") || !strings.HasPrefix(lines[3], "ANY") {
+		t.Errorf("Unexpected table:\n%s", buf.String())
+	}
+}
+
+func TestRouter_StrictRoutes(t *testing.T) {
+	h := func(ctx *Context) {}
+
+	t.Run("Panic on duplicate", func(t *testing.T) {
+		app := New(StrictRoutes(RoutesPanic))
+		app.Get("/user/:id", h)
+		defer func() {
+			rec := recover()
+			err, ok := rec.(*RouteConflictError)
+			if !ok {
+				t.Fatalf("Expected *RouteConflictError panic, got %v", rec)
+			}
+			if !err.Duplicate || !strings.Contains(err.Error(), "ki_test.go:") {
+				t.Fatalf("Unexpected conflict: %v", err)
+			}
+		}()
+		app.Get("/user/:id", h)
+	})
+
+	t.Run("Error mode collects ambiguous routes", func(t *testing.T) {
+		app := New(StrictRoutes(RoutesError))
+		app.Get("/user/:id", h)
+		rb := app.Get("/user/{name}", h)
+		var conflict *RouteConflictError
+		if !errors.As(rb.Err(), &conflict) || conflict.Duplicate {
+			t.Fatalf("Expected ambiguous conflict, got %v", rb.Err())
+		}
+		if conflict.Source == conflict.ExistingSource {
+			t.Fatalf("Expected distinct sources, got %q", conflict.Source)
+		}
+		if app.RouteErrors() == nil {
+			t.Fatal("Expected App.RouteErrors to report the conflict")
+		}
+	})
+
+	t.Run("ANY overlaps every method", func(t *testing.T) {
+		app := New(StrictRoutes(RoutesError))
+		app.Get("/items", h)
+		rb := app.Path("/items").Handle(h)
+		var conflict *RouteConflictError
+		if !errors.As(rb.Err(), &conflict) || conflict.Method != "" || conflict.ExistingMethod != "GET" {
+			t.Fatalf("ANY after GET: %v", rb.Err())
+		}
+		app.Path("/orders/:id").Handle(h)
+		if rb := app.Post("/orders/{n}", h); !errors.As(rb.Err(), &conflict) || conflict.ExistingMethod != "" {
+			t.Fatalf("POST after ANY: %v", rb.Err())
+		}
+	})
+
+	t.Run("Log is the default", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		app := New()
+		app.Get("/dup", h)
+		app.Get("/dup", h)
+		if !strings.Contains(buf.String(), "ruta duplicada GET /dup") {
+			t.Fatalf("log = %q", buf.String())
+		}
+	})
+
+	t.Run("Distinct routes are not conflicts", func(t *testing.T) {
+		app := New(StrictRoutes(RoutesPanic))
+		app.Get("/user/:id([0-9]+)", h)
+		app.Get("/user/:name", h)
+		app.Post("/user/:id", h)
+		app.Path("/user/:id").Method("GET").Headers("X-Version", "2").Handle(h)
+		app.Path("/user/:id").Method("GET").Headers("X-Version", "3").Handle(h)
+		app.Domain("admin.local").Get("/user/:id", h)
+		app.PathPrefix("/user/").Handle(h)
+		if err := app.RouteErrors(); err != nil {
+			t.Fatalf("Unexpected conflict: %v", err)
+		}
+	})
+}
+
+func TestRouter_CatchAll(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "css"), 0755)
+	os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0644)
+
+	app := New()
+	app.Get("/files/*path", func(ctx *Context) {
+		ctx.Text(200, "file:"+ctx.Vars()["path"])
+	}).Name("files")
+	app.Get("/files/readme", func(ctx *Context) {
+		ctx.Text(200, "static")
+	})
+	app.Group("/docs").Get("/{page...}", func(ctx *Context) {
+		ctx.Text(200, "doc:"+ctx.Vars()["page"])
+	})
+	app.Get("/logs/*name([a-z/]+\\.log)", func(ctx *Context) {
+		ctx.Text(200, "log:"+ctx.Vars()["name"])
+	})
+	app.Path("/assets/*path").Static(dir)
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/files/a/b/c.txt":      "file:a/b/c.txt",
+		"/files/readme":         "static",
+		"/files/readme/more":    "file:readme/more",
+		"/docs/guide/intro":     "doc:guide/intro",
+		"/logs/app/current.log": "log:app/current.log",
+		"/assets/css/app.css":   "body{}",
+	} {
+		resp, body := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 200)
+		assertBody(t, body, want)
+	}
+
+	resp, _ := httpGet(t, server.URL+"/logs/app/current.txt")
+	assertStatus(t, resp, 404)
+
+	u, err := app.URL("files", "path", "a b/c.txt")
+	if err != nil || u != "/files/a%20b/c.txt" {
+		t.Fatalf("Unexpected URL %q (%v)", u, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected panic for catch-all in the middle of the pattern")
+		}
+	}()
+	app.Get("/bad/*path/edit", func(ctx *Context) {})
+}
+
+func TestRouter_MixedAndOptionalSegments(t *testing.T) {
+	app := New()
+	vars := func(ctx *Context) {
+		v := ctx.Vars()
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k+"="+v[k])
+		}
+		sort.Strings(keys)
+		ctx.Text(200, strings.Join(keys, ","))
+	}
+	app.Path("/report/{year}-{month}.{format}").RegexVar("year", "[0-9]{4}").Name("report").Method("GET").Handle(vars)
+	app.Get("/posts/:slug?", vars).Name("posts")
+	app.Get("/v{version:[0-9]+}/items", vars).Name("items")
+	app.Get("/tags/{tag?}", vars)
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/report/2024-05.csv": "format=csv,month=05,year=2024",
+		"/posts/hola":         "slug=hola",
+		"/posts":              "",
+		"/v2/items":           "version=2",
+		"/tags":               "",
+		"/tags/go":            "tag=go",
+	} {
+		resp, body := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 200)
+		assertBody(t, body, want)
+	}
+	for _, path := range []string{"/report/24-05.csv", "/report/2024.csv", "/vx/items", "/posts/a/b"} {
+		resp, _ := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 404)
+	}
+
+	for _, c := range []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{"report", []string{"year", "2024", "month", "05", "format", "csv"}, "/report/2024-05.csv"},
+		{"posts", nil, "/posts"},
+		{"posts", []string{"slug", "hola"}, "/posts/hola"},
+		{"items", []string{"version", "3"}, "/v3/items"},
+	} {
+		got, err := app.URL(c.name, c.params...)
+		if err != nil || got != c.want {
+			t.Errorf("URL(%q, %v) = %q, %v; want %q", c.name, c.params, got, err, c.want)
+		}
+	}
+	if _, err := app.URL("items", "version", "x"); err == nil {
+		t.Error("Expected error for version=x")
+	}
+
+	t.Run("Optional conflicts with static", func(t *testing.T) {
+		app := New(StrictRoutes(RoutesError))
+		app.Get("/posts", vars)
+		if err := app.Get("/posts/:slug?", vars).Err(); err == nil {
+			t.Fatal("Expected conflict between /posts and /posts/:slug?")
+		}
+	})
+}
+
+func TestRouter_RegexCompiledAtRegistration(t *testing.T) {
+	app := New()
+	app.Get("/onlynum/:id([0-9]+)", func(ctx *Context) {
+		ctx.Text(200, "ok:"+ctx.Vars()["id"])
+	})
+	app.Path("/code/{code}").RegexVar("code", "[A-Z]{3}").Method("GET").Handle(func(ctx *Context) {
+		ctx.Text(200, "code:"+ctx.Vars()["code"])
+	})
+
+	for path, want := range map[string]int{
+		"/onlynum/42":     200,
+		"/onlynum/abc123": 404,
+		"/onlynum/123abc": 404,
+		"/code/ABC":       200,
+		"/code/xABCx":     404,
+	} {
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+
+	defer func() {
+		rec := recover()
+		msg := fmt.Sprint(rec)
+		if rec == nil || !strings.Contains(msg, "/bad/:id([0-9+)") || !strings.Contains(msg, "ki_test.go:") {
+			t.Fatalf("Expected panic naming the route, got %v", rec)
+		}
+	}()
+	app.Get("/bad/:id([0-9+)", func(ctx *Context) {})
+}
+
+func TestContext_TypedParams(t *testing.T) {
+	app := New()
+	app.RegisterConverter("hex", &Converter{Regex: "[0-9a-f]+", Convert: func(s string) (any, error) {
+		return strconv.ParseUint(s, 16, 64)
+	}})
+	app.Get("/users/{id:int}", func(ctx *Context) {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			ctx.Text(500, err.Error())
+			return
+		}
+		ctx.Text(200, fmt.Sprintf("user:%d", id+1))
+	})
+	app.Get("/events/{at:date}", func(ctx *Context) {
+		at, err := ctx.ParamTime("at", time.DateOnly)
+		if err != nil {
+			ctx.Text(500, err.Error())
+			return
+		}
+		ctx.Text(200, at.Weekday().String())
+	})
+	// El layout pedido manda sobre el parse del converter
+	app.Get("/events/{at:date}/at", func(ctx *Context) {
+		if _, err := ctx.ParamTime("at", time.RFC3339); !errors.As(err, new(*ParamError)) {
+			ctx.Text(500, fmt.Sprint("expected ParamError, got ", err))
+			return
+		}
+		ctx.Text(200, "layout")
+	})
+	app.Get("/posts/{slug:slug}", func(ctx *Context) {
+		ctx.Text(200, "slug")
+	})
+	app.Get("/color/{c:hex}", func(ctx *Context) {
+		v, _ := ctx.ParamValue("c")
+		ctx.Text(200, fmt.Sprint(v))
+	})
+	app.Get("/raw/:id/:key", func(ctx *Context) {
+		if _, err := ctx.ParamInt("id"); !errors.As(err, new(*ParamError)) {
+			ctx.Text(500, "expected ParamError")
+			return
+		}
+		if _, err := ctx.Param("missing"); !errors.Is(err, ErrParamNotFound) {
+			ctx.Text(500, "expected ErrParamNotFound")
+			return
+		}
+		u, err := ctx.ParamUUID("key")
+		if err != nil {
+			ctx.Text(400, err.Error())
+			return
+		}
+		ctx.Text(200, u)
+	})
+
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/users/41":             "user:42",
+		"/events/2024-05-01":    "Wednesday",
+		"/events/2024-05-01/at": "layout",
+		"/posts/hola-mundo":     "slug",
+		"/color/ff":             "255",
+		"/raw/abc/0B3E1F4A-1C2D-4E5F-8A9B-0C1D2E3F4A5B": "0b3e1f4a-1c2d-4e5f-8a9b-0c1d2e3f4a5b",
+	} {
+		resp, body := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 200)
+		assertBody(t, body, want)
+	}
+	for _, path := range []string{"/users/abc", "/posts/Hola_Mundo", "/color/zz"} {
+		resp, _ := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 404)
+	}
+	// Coincide la regex pero no el converter: 400, igual que ctx.ParamInt en un :id
+	for _, path := range []string{"/users/99999999999999999999999", "/events/2024-02-30"} {
+		resp, body := httpGet(t, server.URL+path)
+		assertStatus(t, resp, 400)
+		if !strings.Contains(body, `"code":"invalid_param"`) {
+			t.Errorf("%s: %s", path, body)
+		}
+	}
+	resp, _ := httpGet(t, server.URL+"/raw/abc/not-a-uuid")
+	assertStatus(t, resp, 400)
+
+	// Un ParamError devuelto por el handler responde 400 invalid_param
+	app.Get("/items/:id", func(ctx *Context) error {
+		_, err := ctx.ParamInt("id")
+		return err
+	})
+	resp, body := httpGet(t, server.URL+"/items/abc")
+	assertStatus(t, resp, 400)
+	if !strings.Contains(body, `"code":"invalid_param"`) {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestContext_SetParams(t *testing.T) {
+	app := New()
+	app.Get("/exact", func(ctx *Context) {
+		ctx.Vars()["k"] = "v"
+		ctx.Text(200, ctx.Vars()["k"])
+	})
+	w := httptest.NewRecorder()
+	app.Router.ServeHTTP(w, httptest.NewRequest("GET", "/exact", nil))
+	if w.Body.String() != "v" {
+		t.Errorf("Vars en ruta exacta = %q", w.Body)
+	}
+
+	// Un Context reutilizado (router anidado) no conserva los parámetros anteriores
+	ctx := &Context{}
+	ctx.setParams([]pathParam{{key: "id", value: "7", converted: 7}})
+	ctx.setParams(nil)
+	if _, err := ctx.Param("id"); err == nil {
+		t.Error("el parámetro id no debe filtrarse")
+	}
+	if _, ok := ctx.ParamValue("id"); ok {
+		t.Error("el valor convertido de id no debe filtrarse")
+	}
+}
+
+func TestContext_Bind(t *testing.T) {
+	type base struct {
+		Tenant string `header:"X-Tenant"`
+	}
+	type input struct {
+		base
+		ID     int                   `path:"id"`
+		Page   *int                  `query:"page"`
+		Tags   []string              `query:"tag"`
+		Since  time.Time             `query:"since"`
+		Wait   time.Duration         `query:"wait"`
+		Name   string                `json:"name" xml:"name" form:"name"`
+		Age    uint8                 `json:"age" xml:"age" form:"age"`
+		Avatar *multipart.FileHeader `form:"avatar"`
+	}
+	var got input
+	app := New()
+	app.Post("/users/:id", func(ctx *Context) {
+		got = input{}
+		if err := ctx.Bind(&got); err != nil {
+			ctx.Text(400, err.Error())
+			return
+		}
+		ctx.Text(200, "ok")
+	})
+	send := func(ct string, body io.Reader, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/users/7"+query, body)
+		req.Header.Set("Content-Type", ct)
+		req.Header.Set("X-Tenant", "acme")
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("application/json", strings.NewReader(`{"name":"ana","age":30}`), "?page=2&tag=a&tag=b&since=2024-05-01&wait=1s")
+	if w.Code != 200 {
+		t.Fatalf("json: %d %s", w.Code, w.Body)
+	}
+	if got.ID != 7 || *got.Page != 2 || strings.Join(got.Tags, ",") != "a,b" || got.Tenant != "acme" ||
+		got.Name != "ana" || got.Age != 30 || got.Since.Day() != 1 || got.Wait != time.Second {
+		t.Errorf("json: bind incorrecto %+v", got)
+	}
+
+	w = send("application/xml", strings.NewReader(`<input><name>leo</name><age>5</age></input>`), "")
+	if w.Code != 200 || got.Name != "leo" || got.Age != 5 || got.Page != nil {
+		t.Errorf("xml: %d %+v", w.Code, got)
+	}
+
+	w = send("application/x-www-form-urlencoded", strings.NewReader("name=eva&age=9"), "")
+	if w.Code != 200 || got.Name != "eva" || got.Age != 9 {
+		t.Errorf("form: %d %+v", w.Code, got)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "max")
+	fw, _ := mw.CreateFormFile("avatar", "a.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+	w = send(mw.FormDataContentType(), &buf, "")
+	if w.Code != 200 || got.Name != "max" || got.Avatar == nil || got.Avatar.Filename != "a.png" {
+		t.Errorf("multipart: %d %+v", w.Code, got)
+	}
+
+	// Todos los campos inválidos se reportan juntos
+	req := httptest.NewRequest("POST", "/users/x?page=dos", strings.NewReader(`{"age":300}`))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := &Context{Request: req, params: map[string]string{"id": "x"}}
+	err := ctx.Bind(&got)
+	var bErr *BindError
+	if !errors.As(err, &bErr) || len(bErr.Fields) != 3 {
+		t.Fatalf("se esperaban 3 errores (body, path, query), got %v", err)
+	}
+	if bErr.Fields[1].Source != "path" || bErr.Fields[1].Field != "ID" || bErr.Fields[2].Key != "page" {
+		t.Errorf("errores inesperados: %+v", bErr.Fields)
+	}
+	if err := ctx.Bind(got); err == nil {
+		t.Error("Bind sin puntero debería fallar")
+	}
+}
+
+func TestContext_Validate(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type input struct {
+		Name    string    `json:"name" validate:"required,min=3,max=10"`
+		Email   string    `json:"email" validate:"required,email"`
+		Role    string    `json:"role" validate:"oneof=admin user"`
+		Code    string    `json:"code" validate:"omitempty,regex=^[A-Z]{2,3}$"`
+		Age     *int      `json:"age" validate:"required,min=18"`
+		Pin     string    `json:"pin" validate:"len=4"`
+		Tags    []string  `json:"tags" validate:"max=3,dive,min=2"`
+		Address address   `json:"address"`
+		Extra   []address `json:"extra" validate:"dive"`
+		Lucky   int       `json:"lucky" validate:"even"`
+	}
+	app := New()
+	app.RegisterValidation("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+	app.Post("/users", func(ctx *Context) error {
+		var in input
+		if err := ctx.BindAndValidate(&in); err != nil {
+			return err
+		}
+		ctx.Text(201, "ok")
+		return nil
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"name":"ana","email":"ana@example.com","role":"admin","code":"AB","age":20,"pin":"1234",
+		"tags":["go","ki"],"address":{"city":"Lima"},"extra":[{"city":"Quito"}],"lucky":4}`)
+	if w.Code != 201 {
+		t.Fatalf("válido: %d %s", w.Code, w.Body)
+	}
+
+	w = post(`{"name":"al","email":"nope","role":"root","code":"abc","pin":"12",
+		"tags":["go","k"],"extra":[{"city":""}],"lucky":3}`)
+	if w.Code != 422 {
+		t.Fatalf("inválido: %d %s", w.Code, w.Body)
+	}
+	var res struct {
+		Meta struct {
+			Success bool `json:"success"`
+		} `json:"meta"`
+		Body []FieldViolation `json:"body"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range res.Body {
+		got[f.Field] = f.Rule
+	}
+	want := map[string]string{
+		"name": "min", "email": "email", "role": "oneof", "code": "regex", "age": "required",
+		"pin": "len", "tags[1]": "min", "address.city": "required", "extra[0].city": "required", "lucky": "even",
+	}
+	if res.Meta.Success || !reflect.DeepEqual(got, want) {
+		t.Errorf("violaciones = %v, se esperaba %v", got, want)
+	}
+
+	// Errores de Bind: 400
+	w = post(`{"age":"veinte"}`)
+	if w.Code != 400 {
+		t.Errorf("bind: %d %s", w.Code, w.Body)
+	}
+
+	// Un tag que no aplica al campo es un error del programa, no un panic por petición
+	for _, v := range []any{
+		&struct {
+			Active bool `validate:"min=3"`
+		}{},
+		&struct {
+			Name string `validate:"max=abc"`
+		}{},
+		&struct {
+			At time.Time `validate:"min=1"`
+		}{},
+		&struct {
+			Addr address `validate:"len=2"`
+		}{},
+	} {
+		err := (&Context{App: app}).Validate(v)
+		if err == nil || errors.As(err, new(*ValidationError)) {
+			t.Errorf("%T: %v", v, err)
+		}
+	}
+	bad := &struct {
+		Items []struct {
+			Active bool `validate:"max=1"`
+		} `validate:"dive"`
+	}{}
+	if err := (&Context{App: app}).Validate(bad); err == nil || !strings.Contains(err.Error(), "Items[].Active") {
+		t.Errorf("dive: %v", err)
+	}
+}
+
+type createUserInput struct {
+	In
+	Org  string `path:"org"`
+	Name string `json:"name" validate:"required,min=3"`
+}
+
+type createdUser struct {
+	ID   int    `json:"id"`
+	Org  string `json:"org"`
+	Name string `json:"name"`
+}
+
+func (createdUser) StatusCode() int { return http.StatusCreated }
+
+func TestHandler_InputInjection(t *testing.T) {
+	app := New()
+	app.Provide(func() *mockService { return &mockService{Value: "svc"} })
+	app.Post("/orgs/:org/users", func(in createUserInput, svc *mockService) (createdUser, error) {
+		return createdUser{ID: 1, Org: in.Org, Name: in.Name + "@" + svc.Value}, nil
+	})
+	app.Post("/ptr", func(in *createUserInput) (*createdUser, error) {
+		if in.Name == "nadie" {
+			return nil, nil
+		}
+		return nil, errors.New("falló")
+	})
+	app.Post("/list", func(svc *mockService) ([]createdUser, error) {
+		return nil, nil
+	})
+	app.Post("/map", func() map[string]int {
+		return nil
+	})
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/orgs/acme/users", `{"name":"ana"}`)
+	if w.Code != 201 || strings.TrimSpace(w.Body.String()) != `{"id":1,"org":"acme","name":"ana@svc"}` {
+		t.Errorf("got %d %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if w := post("/orgs/acme/users", `{"name":"al"}`); w.Code != 422 {
+		t.Errorf("validación: %d %s", w.Code, w.Body)
+	}
+	if w := post("/list", `{}`); w.Code != 200 || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("slice nil: %d %q", w.Code, w.Body)
+	}
+	if w := post("/map", `{}`); w.Code != 200 || strings.TrimSpace(w.Body.String()) != "{}" {
+		t.Errorf("map nil: %d %q", w.Code, w.Body)
+	}
+	if w := post("/ptr", `{"name":"nadie"}`); w.Code != 204 {
+		t.Errorf("nil: %d %s", w.Code, w.Body)
+	}
+	if w := post("/ptr", `{"name":"error"}`); w.Code != 500 || !strings.Contains(w.Body.String(), "falló") {
+		t.Errorf("error: %d %s", w.Code, w.Body)
+	}
+
+	// Renderer propio
+	app.RenderResults(func(ctx *Context, code int, v any) error {
+		ctx.Text(code, fmt.Sprintf("%+v", v))
+		return nil
+	})
+	if w := post("/orgs/x/users", `{"name":"leo"}`); w.Body.String() != "{ID:1 Org:x Name:leo@svc}" {
+		t.Errorf("renderer: %s", w.Body)
+	}
+
+	// Los errores de binding y validación pasan por un middleware global sin retorno
+	app = New()
+	app.Use(func(ctx *Context) {
+		ctx.Next()
+	})
+	app.Post("/orgs/:org/users", func(in createUserInput) createdUser {
+		return createdUser{Org: in.Org, Name: in.Name}
+	})
+	if w := post("/orgs/acme/users", `{"name":"al"}`); w.Code != 422 {
+		t.Errorf("validación con middleware: %d %s", w.Code, w.Body)
+	}
+	if w := post("/orgs/acme/users", `{"name":`); w.Code != 400 {
+		t.Errorf("binding con middleware: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	app := New()
+	app.MapError(func(err error) *HTTPError {
+		if errors.Is(err, sql.ErrNoRows) {
+			return NotFoundError("no existe").WithCode("not_found")
+		}
+		return nil
+	})
+	app.Get("/user", func(ctx *Context) error {
+		return fmt.Errorf("buscando: %w", Unauthorized("").WithDetails(M{"realm": "api"}))
+	})
+	app.Get("/row", func() error {
+		return fmt.Errorf("repo: %w", sql.ErrNoRows)
+	})
+	app.Get("/plain", func() error {
+		return errors.New("boom")
+	})
+	guard := func(ctx *Context) {
+		panic(BadRequest("token inválido").WithCause(errors.New("detalle interno")))
+	}
+	app.Get("/panic", func(ctx *Context) {}, guard)
+	app.Get("/next", func(ctx *Context) error {
+		return Conflict("ya existe")
+	}, func(ctx *Context) error {
+		return ctx.Next()
+	})
+	// Un middleware sin retorno no se come el error del handler
+	app.Get("/void", func(ctx *Context) error {
+		return Conflict("ya existe")
+	}, func(ctx *Context) {
+		ctx.Next()
+	})
+	// Uno que devuelve error puede manejarlo y devolver nil
+	app.Get("/handled", func(ctx *Context) error {
+		return Conflict("ya existe")
+	}, func(ctx *Context) error {
+		if err := ctx.Next(); err != nil {
+			ctx.Text(200, "manejado")
+		}
+		return nil
+	})
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/user", 401, `{"body":{"realm":"api"},"meta":{"message":"Unauthorized","success":false}}`},
+		{"/row", 404, `{"body":null,"meta":{"code":"not_found","message":"no existe","success":false}}`},
+		{"/plain", 500, "boom"},
+		{"/panic", 400, `{"body":null,"meta":{"message":"token inválido","success":false}}`},
+		{"/next", 409, `{"body":null,"meta":{"message":"ya existe","success":false}}`},
+		{"/void", 409, `{"body":null,"meta":{"message":"ya existe","success":false}}`},
+		{"/handled", 200, "manejado"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.status || strings.TrimSpace(w.Body.String()) != c.body {
+			t.Errorf("%s: got %d %s, want %d %s", c.path, w.Code, w.Body, c.status, c.body)
+		}
+	}
+
+	// OnError recibe el error ya traducido, con la causa original
+	var got error
+	app.OnError(func(ctx *Context, err error) {
+		got = err
+		ctx.Text(ToHTTPError(err).Status, "custom")
+	})
+	w := httptest.NewRecorder()
+	app.Router.ServeHTTP(w, httptest.NewRequest("GET", "/row", nil))
+	if w.Code != 404 || !errors.Is(got, sql.ErrNoRows) {
+		t.Errorf("OnError: %d %v", w.Code, got)
+	}
+	if he := ToHTTPError(errors.New("x")); he.Status != 500 || he.Message != "Internal Server Error" {
+		t.Errorf("ToHTTPError = %+v", he)
+	}
+}
+
+func TestApp_ProblemDetails(t *testing.T) {
+	reg, err := templates.New(templates.DirFS(fstest.MapFS{
+		"error.html": {Data: []byte(`<h1>{{ .Status }} {{ .Title }}</h1>`)},
+	}), templates.Suffix(".html"))
+	if err != nil {
+		t.Fatalf("templates.New failed: %v", err)
+	}
+	app := New(SetTemplateEngine(reg))
+	app.UseProblemDetails(ProblemOptions{TypeBase: "https://example.com/problems/"})
+	type input struct {
+		Name string `json:"name" validate:"required"`
+	}
+	app.Post("/users", func(ctx *Context) error {
+		var in input
+		return ctx.BindAndValidate(&in)
+	})
+	app.Get("/missing", func() error {
+		return NotFoundError("no existe").WithCode("user_not_found").WithDetails(M{"id": 7})
+	})
+	app.Get("/panic", func(ctx *Context) {
+		panic("secreto")
+	})
+	app.Get("/fail", func(ctx *Context) {
+		ctx.Fail(409, errors.New("duplicado"))
+	})
+
+	do := func(method, path, accept string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		var m map[string]any
+		json.Unmarshal(w.Body.Bytes(), &m)
+		return w, m
+	}
+
+	w, p := do("POST", "/users", "")
+	if w.Code != 422 || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("validación: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if p["type"] != "https://example.com/problems/validation_failed" || p["instance"] != "/users" || len(p["errors"].([]any)) != 1 {
+		t.Errorf("validación: %v", p)
+	}
+
+	_, p = do("GET", "/missing", "application/json")
+	if p["status"] != 404.0 || p["title"] != "Not Found" || p["detail"] != "no existe" || p["id"] != 7.0 {
+		t.Errorf("not found: %v", p)
+	}
+	w, p = do("GET", "/panic", "")
+	if w.Code != 500 || p["detail"] != nil || strings.Contains(w.Body.String(), "secreto") {
+		t.Errorf("panic: %d %s", w.Code, w.Body)
+	}
+	if _, p = do("GET", "/fail", ""); p["status"] != 409.0 || p["detail"] != "duplicado" {
+		t.Errorf("fail: %v", p)
+	}
+	if _, p = do("GET", "/nada", ""); p["status"] != 404.0 {
+		t.Errorf("404: %v", p)
+	}
+	if _, p = do("DELETE", "/fail", ""); p["status"] != 405.0 {
+		t.Errorf("405: %v", p)
+	}
+
+	// Navegadores: HTML con el TemplateEngine
+	w, _ = do("GET", "/missing", "text/html,application/xhtml+xml,*/*;q=0.8")
+	if w.Code != 404 || w.Body.String() != "<h1>404 Not Found</h1>" {
+		t.Errorf("html: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestApp_ProblemDetailsKeepsOnError(t *testing.T) {
+	custom := func(ctx *Context, err error) {
+		if errors.Is(err, errTeapot) {
+			ctx.Text(418, "propio")
+			return
+		}
+		ctx.Problem(err)
+	}
+	for name, setup := range map[string]func(app *App){
+		"OnError antes":   func(app *App) { app.OnError(custom); app.UseProblemDetails(ProblemOptions{}) },
+		"OnError después": func(app *App) { app.UseProblemDetails(ProblemOptions{}); app.OnError(custom) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := New()
+			setup(app)
+			app.Get("/teapot", func() error { return errTeapot })
+			app.Get("/otro", func() error { return NewHTTPError(409, "duplicado") })
+
+			w := httptest.NewRecorder()
+			app.Router.ServeHTTP(w, httptest.NewRequest("GET", "/teapot", nil))
+			if w.Code != 418 || w.Body.String() != "propio" {
+				t.Errorf("OnError reemplazado: %d %s", w.Code, w.Body)
+			}
+			w = httptest.NewRecorder()
+			app.Router.ServeHTTP(w, httptest.NewRequest("GET", "/otro", nil))
+			if w.Code != 409 || w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("ctx.Problem: %d %s", w.Code, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+var errTeapot = errors.New("teapot")
+
+type respondUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestContext_Respond(t *testing.T) {
+	reg, err := templates.New(templates.DirFS(fstest.MapFS{
+		"user.html": {Data: []byte(`<p>{{ .Name }}</p>`)},
+	}), templates.Suffix(".html"))
+	if err != nil {
+		t.Fatalf("templates.New failed: %v", err)
+	}
+	app := New(SetTemplateEngine(reg))
+	app.RegisterRenderer("application/x-upper", &Renderer{
+		Render: func(ctx *Context, code int, data any, _ *RespondOptions) error {
+			ctx.Writer.Header().Set("Content-Type", "application/x-upper")
+			ctx.Text(code, strings.ToUpper(data.(respondUser).Name))
+			return nil
+		},
+	})
+	app.Get("/user", func(ctx *Context) error {
+		return ctx.Respond(200, respondUser{Name: "ana"}, WithTemplate("user.html"))
+	})
+	app.Get("/rows", func(ctx *Context) error {
+		return ctx.Respond(200, [][]string{{"a", "b"}})
+	})
+	app.Get("/json-only", func(ctx *Context) error {
+		return ctx.Respond(200, respondUser{Name: "ana"}, Offer("application/json"))
+	})
+
+	cases := []struct {
+		path, accept string
+		status       int
+		ctype, body  string
+	}{
+		{"/user", "", 200, "application/json", `{"name":"ana"}`},
+		{"/user", "application/xml", 200, "application/xml", `<respondUser><name>ana</name></respondUser>`},
+		{"/user", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", 200, "text/html", `<p>ana</p>`},
+		{"/user", "application/json;q=0.5, application/x-upper", 200, "application/x-upper", `ANA`},
+		{"/user", "text/*;q=0.5, application/*;q=0.1", 200, "text/html", `<p>ana</p>`},
+		{"/rows", "text/csv", 200, "text/csv", "a,b"},
+		{"/user", "text/csv", 406, "text/plain", "Not Acceptable"},
+		{"/json-only", "application/xml", 406, "text/plain", "Not Acceptable: application/json"},
+		{"/json-only", "*/*;q=0, application/json", 200, "application/json", `{"name":"ana"}`},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.path, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		body := strings.TrimSpace(w.Body.String())
+		if w.Code != c.status || !strings.HasPrefix(w.Header().Get("Content-Type"), c.ctype) || !strings.HasPrefix(body, c.body) {
+			t.Errorf("%s [%s]: got %d %q %q", c.path, c.accept, w.Code, w.Header().Get("Content-Type"), body)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: falta Vary: Accept", c.path)
+		}
+	}
+}
+
+func TestContext_SSE(t *testing.T) {
+	app := New()
+	calls := 0
+	gone := make(chan error, 1)
+	app.Path("/events").Cache(time.Minute).Handle(func(ctx *Context) error {
+		calls++
+		sse, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		sse.Retry(2 * time.Second)
+		sse.Send(SSEEvent{ID: "7", Event: "progress", Data: M{"pct": 50}})
+		sse.Event("log", "línea 1\nlínea 2")
+		sse.Data("desde " + sse.LastEventID())
+		return nil
+	})
+	app.Get("/forever", func(ctx *Context) {
+		sse, err := ctx.SSE()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		sse.Heartbeat(10 * time.Millisecond)
+		<-sse.Done()
+		time.Sleep(20 * time.Millisecond)
+		gone <- sse.Data("tarde")
+	})
+	server := httptest.NewServer(LoggingHandlerWithOutput(io.Discard, app.Router))
+	defer server.Close()
+
+	want := "retry: 2000\n\n" +
+		"id: 7\nevent: progress\ndata: {\"pct\":50}\n\n" +
+		"event: log\ndata: línea 1\ndata: línea 2\n\n" +
+		"data: desde 6\n\n"
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+		req.Header.Set("Last-Event-ID", "6")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if string(body) != want {
+			t.Errorf("stream = %q, want %q", body, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("SSE no debe cachearse: %d llamadas", calls)
+	}
+
+	// El heartbeat llega y la desconexión del cliente termina el stream
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/forever", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	if line != ": ping\n" {
+		t.Errorf("heartbeat = %q", line)
+	}
+	cancel()
+	resp.Body.Close()
+	select {
+	case err := <-gone:
+		if err == nil {
+			t.Error("escribir tras la desconexión debería fallar")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("el handler no terminó al cancelar el contexto")
+	}
+}
+
+// newAppServer sirve app con el http.Server que arma Run (timeouts incluidos).
+func newAppServer(t *testing.T, app *App) *httptest.Server {
+	t.Helper()
+	srv, err := app.newServer(app.Router, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(app.Router)
+	server.Config = srv
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestContext_SSE_WriteTimeout(t *testing.T) {
+	app := New(SetWriteTimeout(300 * time.Millisecond))
+	app.Get("/events", func(ctx *Context) error {
+		sse, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 5; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if err := sse.Data(strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	server := newAppServer(t, app)
+
+	// El stream dura más que el WriteTimeout y llega completo
+	_, body := httpGet(t, server.URL+"/events")
+	assertBody(t, body, "data: 0\n\ndata: 1\n\ndata: 2\n\ndata: 3\n\ndata: 4\n\n")
+}
+
+func TestContext_Streams_WriteTimeout(t *testing.T) {
+	app := New(SetWriteTimeout(300 * time.Millisecond))
+	app.Get("/ndjson", func(ctx *Context) error {
+		out, err := ctx.StreamNDJSON(200)
+		if err != nil {
+			return err
+		}
+		out.FlushEvery(1)
+		for i := 0; i < 5; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if err := out.Write(i); err != nil {
+				return err
+			}
+		}
+		return out.Close()
+	})
+	app.Get("/stream", func(ctx *Context) error {
+		i := 0
+		return ctx.Stream(func(w io.Writer) bool {
+			time.Sleep(100 * time.Millisecond)
+			fmt.Fprintln(w, i)
+			i++
+			return i < 5
+		})
+	})
+	server := newAppServer(t, app)
+
+	for _, path := range []string{"/ndjson", "/stream"} {
+		_, body := httpGet(t, server.URL+path)
+		if body != "0\n1\n2\n3\n4\n" {
+			t.Errorf("%s = %q", path, body)
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	app := New()
+	app.Provide(func() *mockService { return &mockService{Value: "inyectado"} })
+	closed := make(chan error, 4)
+	app.Path("/echo").WebSocket(func(conn *WSConn, svc *mockService) error {
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err
+				return nil
+			}
+			if string(msg) == "svc" {
+				msg = []byte(svc.Value)
+			}
+			if err := conn.WriteMessage(typ, msg); err != nil {
+				return err
+			}
+		}
+	}, WSCompression(true), WSMaxMessageSize(1024), WSSubprotocols("chat"))
+	app.Get("/manual", func(ctx *Context) error {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return conn.WriteJSON(M{"ok": true})
+	})
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	ctx := context.Background()
+
+	conn, resp, err := DialWebSocket(ctx, wsURL+"/echo", nil,
+		WSCompression(true), WSFragmentSize(3), WSSubprotocols("v2", "chat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 101 || conn.Subprotocol() != "chat" || !conn.Compressed() {
+		t.Fatalf("handshake: %d %q compresión=%v", resp.StatusCode, conn.Subprotocol(), conn.Compressed())
+	}
+	for _, m := range []struct {
+		typ       int
+		send, got string
+	}{
+		{WSText, "hola mundo", "hola mundo"},
+		{WSBinary, "\x00\x01\x02", "\x00\x01\x02"},
+		{WSText, "svc", "inyectado"},
+		{WSText, strings.Repeat("ki", 300), strings.Repeat("ki", 300)},
+	} {
+		if err := conn.WriteMessage(m.typ, []byte(m.send)); err != nil {
+			t.Fatal(err)
+		}
+		typ, got, err := conn.ReadMessage()
+		if err != nil || typ != m.typ || string(got) != m.got {
+			t.Errorf("echo %q: %d %q %v", m.send, typ, got, err)
+		}
+	}
+
+	// Ping/pong: el pong llega mientras se lee el siguiente mensaje
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) { pong <- string(data) })
+	conn.Ping([]byte("p1"))
+	conn.WriteText("tras ping")
+	if _, got, _ := conn.ReadMessage(); string(got) != "tras ping" || <-pong != "p1" {
+		t.Errorf("ping/pong: %q", got)
+	}
+
+	// Cierre con código propio
+	conn.CloseWithCode(4000, "adiós")
+	var ce *WSCloseError
+	if err := <-closed; !errors.As(err, &ce) || ce.Code != 4000 || ce.Reason != "adiós" {
+		t.Errorf("cierre: %v", err)
+	}
+
+	// Mensaje demasiado grande: 1009
+	conn, _, err = DialWebSocket(ctx, wsURL+"/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(WSBinary, make([]byte, 2048))
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &ce) || ce.Code != WSCloseMessageTooBig {
+		t.Errorf("límite: %v", err)
+	}
+	<-closed
+
+	// Frame de cliente sin máscara: error de protocolo
+	conn, _, err = DialWebSocket(ctx, wsURL+"/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.server = true
+	conn.WriteText("sin máscara")
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &ce) || ce.Code != WSCloseProtocolError {
+		t.Errorf("máscara: %v", err)
+	}
+	<-closed
+
+	// Upgrade manual y JSON
+	conn, _, err = DialWebSocket(ctx, wsURL+"/manual", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got M
+	if err := conn.ReadJSON(&got); err != nil || got["ok"] != true {
+		t.Errorf("manual: %v %v", got, err)
+	}
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &ce) || ce.Code != WSCloseNormal {
+		t.Errorf("cierre del servidor: %v", err)
+	}
+
+	// Handshakes inválidos
+	resp, _ = httpGet(t, server.URL+"/echo")
+	assertStatus(t, resp, 400)
+	req, _ := http.NewRequest("GET", server.URL+"/echo", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 426 || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("versión: %d", resp.StatusCode)
+	}
+	_, resp, err = DialWebSocket(ctx, wsURL+"/echo", http.Header{"Origin": {"http://evil.example"}})
+	if err == nil || resp.StatusCode != 403 {
+		t.Errorf("origen: %v", err)
+	}
+}
+
+func TestContext_Streams(t *testing.T) {
+	app := New()
+	release := make(chan struct{})
+	stopped := make(chan error, 1)
+	app.Path("/csv").Cache(time.Minute).Handle(func(ctx *Context) error {
+		out, err := ctx.StreamCSV(200, []string{"id", "name"})
+		if err != nil {
+			return err
+		}
+		for i := 1; i <= 3; i++ {
+			if err := out.Write([]string{strconv.Itoa(i), "n,\"" + strconv.Itoa(i)}); err != nil {
+				return err
+			}
+		}
+		return out.Close()
+	})
+	app.Get("/array", func(ctx *Context) error {
+		out, err := ctx.StreamJSONArray(200)
+		if err != nil {
+			return err
+		}
+		out.Write(M{"id": 1})
+		out.Write(M{"id": 2})
+		return nil // el "]" se escribe al terminar el handler
+	})
+	app.Get("/ndjson", func(ctx *Context) error {
+		out, err := ctx.StreamNDJSON(200)
+		if err != nil {
+			return err
+		}
+		out.FlushEvery(1)
+		out.Write(M{"id": 1})
+		<-release
+		return out.Write(M{"id": 2})
+	})
+	app.Get("/forever", func(ctx *Context) {
+		stopped <- ctx.Stream(func(w io.Writer) bool {
+			io.WriteString(w, "tick\n")
+			time.Sleep(time.Millisecond)
+			return true
+		})
+	})
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	resp, body := httpGet(t, server.URL+"/csv")
+	assertStatus(t, resp, 200)
+	assertBody(t, body, "id,name\n1,\"n,\"\"1\"\n2,\"n,\"\"2\"\n3,\"n,\"\"3\"\n")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	_, body = httpGet(t, server.URL+"/array")
+	var arr []M
+	if err := json.Unmarshal([]byte(body), &arr); err != nil || len(arr) != 2 {
+		t.Errorf("array %q: %v", body, err)
+	}
+
+	// El primer elemento llega antes de que el handler termine
+	resp, err := http.Get(server.URL + "/ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(resp.Body)
+	if line, _ := br.ReadString('\n'); line != "{\"id\":1}\n" {
+		t.Errorf("primera línea = %q", line)
+	}
+	close(release)
+	if rest, _ := io.ReadAll(br); string(rest) != "{\"id\":2}\n" {
+		t.Errorf("resto = %q", rest)
+	}
+	resp.Body.Close()
+
+	// Stream termina cuando el cliente se desconecta
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/forever", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bufio.NewReader(resp.Body).ReadString('\n')
+	cancel()
+	resp.Body.Close()
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Stream = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stream no terminó al desconectarse el cliente")
+	}
+}
+
+func TestContext_File(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "reporte año.csv")
+	if err := os.WriteFile(name, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	os.Chtimes(name, modTime, modTime)
+
+	app := New()
+	app.Get("/file", func(ctx *Context) error { return ctx.File(name) })
+	app.Get("/download", func(ctx *Context) error { return ctx.Attachment(name, "") })
+	app.Get("/inline", func(ctx *Context) error { return ctx.Inline(name, "vista \"1\".csv") })
+	app.Get("/missing", func(ctx *Context) error { return ctx.File(filepath.Join(dir, "nada.txt")) })
+	app.Get("/fs/*name", func(ctx *Context) error {
+		return ctx.FileFS(fstest.MapFS{"logo.svg": {Data: []byte("<svg/>")}}, ctx.Vars()["name"])
+	})
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/file")
+	etag := w.Header().Get("Etag")
+	if w.Code != 200 || w.Body.String() != "0123456789" || etag == "" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("file: %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if w := do("/file", "Range", "bytes=2-4"); w.Code != 206 || w.Body.String() != "234" || w.Header().Get("Content-Range") != "bytes 2-4/10" {
+		t.Errorf("range: %d %q", w.Code, w.Body)
+	}
+	if w := do("/file", "Range", "bytes=2-4", "If-Range", `"otro"`); w.Code != 200 || w.Body.Len() != 10 {
+		t.Errorf("if-range: %d %q", w.Code, w.Body)
+	}
+	// Reanudar con el ETag de la propia respuesta
+	if w := do("/file", "Range", "bytes=2-4", "If-Range", etag); w.Code != 206 || w.Body.String() != "234" {
+		t.Errorf("if-range etag: %d %q", w.Code, w.Body)
+	}
+	if w := do("/file", "If-None-Match", etag); w.Code != 304 {
+		t.Errorf("if-none-match: %d", w.Code)
+	}
+	if w := do("/file", "If-Modified-Since", modTime.Add(time.Hour).Format(http.TimeFormat)); w.Code != 304 {
+		t.Errorf("if-modified-since: %d", w.Code)
+	}
+
+	want := `attachment; filename="reporte ano.csv"; filename*=UTF-8''reporte%20a%C3%B1o.csv`
+	if got := do("/download").Header().Get("Content-Disposition"); got != want {
+		t.Errorf("attachment = %q", got)
+	}
+	want = `inline; filename="vista _1_.csv"; filename*=UTF-8''vista%20%221%22.csv`
+	if got := do("/inline").Header().Get("Content-Disposition"); got != want {
+		t.Errorf("inline = %q", got)
+	}
+
+	w = do("/fs/logo.svg")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "image/svg+xml" || w.Header().Get("Etag") == "" {
+		t.Errorf("fs: %d %v", w.Code, w.Header())
+	}
+	if w := do("/fs/logo.svg", "Range", "bytes=0-3", "If-Range", w.Header().Get("Etag")); w.Code != 206 || w.Body.String() != "<svg" {
+		t.Errorf("fs if-range: %d %q", w.Code, w.Body)
+	}
+	for _, path := range []string{"/missing", "/fs/nada.svg", "/fs/../secreto"} {
+		if w := do(path); w.Code != 404 {
+			t.Errorf("%s: %d", path, w.Code)
+		}
+	}
+}
+
+func TestContext_Upload(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp) // archivos temporales de multipart
+	dst := t.TempDir()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+	app := New()
+	app.Path("/upload").Method("POST").MaxBodySize(4 << 10).MaxMemory(16).Handle(func(ctx *Context) error {
+		fh, err := ctx.FormFile("doc", "image/*")
+		if err != nil {
+			return err
+		}
+		return ctx.SaveUploadedFile(fh, filepath.Join(dst, "sub", fh.Filename))
+	})
+	app.Post("/stream", func(ctx *Context) error {
+		mr, err := ctx.MultipartReader()
+		if err != nil {
+			return err
+		}
+		var types []string
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			body, ct, err := SniffContentType(part)
+			if err != nil {
+				return err
+			}
+			data, _ := io.ReadAll(body)
+			types = append(types, fmt.Sprintf("%s:%s:%d", part.FormName(), ct, len(data)))
+		}
+		ctx.Text(200, strings.Join(types, ","))
+		return nil
+	})
+	upload := func(path, field, filename string, content []byte) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile(field, filename)
+		fw.Write(content)
+		mw.Close()
+		req := httptest.NewRequest("POST", path, &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload("/upload", "doc", "foto.png", png); w.Code != 200 {
+		t.Fatalf("upload: %d %s", w.Code, w.Body)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sub", "foto.png")); err != nil || !bytes.Equal(data, png) {
+		t.Errorf("archivo guardado: %v", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("quedaron archivos temporales: %v", entries)
+	}
+
+	cases := []struct {
+		name, field, file string
+		content           []byte
+		status            int
+		code              string
+	}{
+		{"tipo no permitido", "doc", "foto.png", []byte("hola, no soy una imagen"), 415, "unsupported_media_type"},
+		{"falta el archivo", "otro", "foto.png", png, 400, ""},
+		{"demasiado grande", "doc", "foto.png", bytes.Repeat(png, 100), 413, "body_too_large"},
+	}
+	for _, c := range cases {
+		w := upload("/upload", c.field, c.file, c.content)
+		var got struct{ Meta struct{ Code string } }
+		json.Unmarshal(w.Body.Bytes(), &got)
+		if w.Code != c.status || got.Meta.Code != c.code {
+			t.Errorf("%s: %d %s", c.name, w.Code, w.Body)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	app.Router.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Errorf("no multipart: %d", w.Code)
+	}
+
+	if w := upload("/stream", "doc", "foto.png", png); w.Code != 200 || w.Body.String() != "doc:image/png:72" {
+		t.Errorf("stream: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	app := New(SetBodyLimit(16))
+	read := func(ctx *Context) error {
+		data, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		ctx.Text(200, strconv.Itoa(len(data)))
+		return nil
+	}
+	app.Post("/app", read)
+	app.Post("/mw", read, BodyLimit(8))
+	app.Group("/big").BodyLimit(1024).Post("/g", read)
+	app.Path("/free").Method("POST").BodyLimit(0).Handle(read)
+	app.Post("/form", func(ctx *Context) error {
+		var in struct {
+			Name string `form:"name"`
+		}
+		return ctx.Bind(&in)
+	})
+	do := func(path string, n int, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(strings.Repeat("x", n)))
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	cases := []struct {
+		path    string
+		n       int
+		chunked bool
+		status  int
+	}{
+		{"/app", 10, false, 200},
+		{"/app", 20, false, 413}, // Content-Length, sin leer
+		{"/app", 20, true, 413},  // MaxBytesReader
+		{"/mw", 12, true, 413},
+		{"/big/g", 100, false, 200},
+		{"/big/g", 2000, true, 413},
+		{"/free", 5000, false, 200},
+	}
+	for _, c := range cases {
+		if w := do(c.path, c.n, c.chunked); w.Code != c.status {
+			t.Errorf("%s (%d bytes): %d %s", c.path, c.n, w.Code, w.Body)
+		}
+	}
+	w := do("/app", 20, true)
+	var got struct{ Meta struct{ Code string } }
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Meta.Code != "body_too_large" {
+		t.Errorf("413 body = %s", w.Body)
+	}
+
+	req := httptest.NewRequest("POST", "/form", strings.NewReader("name="+strings.Repeat("x", 40)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	app.Router.ServeHTTP(w, req)
+	if w.Code != 413 {
+		t.Errorf("form: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestBodyLimit_MinReadRate(t *testing.T) {
+	app := New()
+	app.Path("/upload").Method("POST").BodyLimit(0, MinReadRate(1000, 50*time.Millisecond)).Handle(func(ctx *Context) error {
+		data, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		ctx.Text(200, strconv.Itoa(len(data)))
+		return nil
+	})
+	srv := httptest.NewServer(app.Router)
+	defer srv.Close()
+
+	// Cliente constante: termina normalmente
+	resp, err := http.Post(srv.URL+"/upload", "text/plain", strings.NewReader(strings.Repeat("x", 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("rápido: %d", resp.StatusCode)
+	}
+
+	// Cliente que deja de enviar: se corta con 408
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("hola"))
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err = client.Post(srv.URL+"/upload", "text/plain", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("lento: %d", resp.StatusCode)
+	}
+}
+
+func TestBodyLimit_MinReadRateLargeBody(t *testing.T) {
+	// Con ~10 GB leídos n*time.Second desbordaría y el deadline quedaría en el pasado
+	start := time.Now()
+	b := &slowBodyReader{start: start, n: 10 << 30, minRate: 1 << 20, grace: time.Second}
+	if want := start.Add(time.Second + 10240*time.Second); !b.deadline().Equal(want) {
+		t.Errorf("deadline = %v, want %v", b.deadline().Sub(start), want.Sub(start))
+	}
+}
+
+func TestApp_RunShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	t.Setenv("PORT", port)
+	base := "http://127.0.0.1:" + port
+
+	app := New(SetShutdownDelay(100*time.Millisecond), SetDrainTimeout(5*time.Second))
+	app.Inject(&mockService{Value: "db"})
+	var events []string
+	var mu sync.Mutex
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	app.OnStart(func(ctx context.Context) { record("start") })
+	app.OnShutdown(func(svc *mockService) error {
+		record("close " + svc.Value)
+		return nil
+	})
+	app.OnShutdown(func(ctx context.Context) error {
+		record(fmt.Sprintf("flush ready=%v", app.Ready()))
+		return errors.New("flush falló")
+	})
+	entered := make(chan struct{})
+	app.Get("/slow", func(ctx *Context) {
+		close(entered)
+		time.Sleep(300 * time.Millisecond)
+		ctx.Text(200, "terminado")
+	})
+	app.Get("/readyz", app.ReadinessHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Get(base + "/readyz"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("readyz antes de apagar: %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	slow := make(chan string, 1)
+	go func() {
+		_, body := httpGet(t, base+"/slow")
+		slow <- body
+	}()
+	<-entered
+	cancel()
+
+	// Durante el shutdown delay sigue aceptando, pero ya no está listo
+	time.Sleep(30 * time.Millisecond)
+	if resp, body := httpGet(t, base+"/readyz"); resp.StatusCode != 503 {
+		t.Errorf("readyz durante el apagado: %d %s", resp.StatusCode, body)
+	}
+	select {
+	case <-app.Done():
+	default:
+		t.Error("App.Done debe cerrarse al empezar el apagado")
+	}
+
+	if body := <-slow; body != "terminado" {
+		t.Errorf("la petición en curso no terminó: %q", body)
+	}
+	select {
+	case err := <-runErr:
+		if err == nil || err.Error() != "flush falló" {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run no terminó")
+	}
+	want := []string{"start", "flush ready=false", "close db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks = %v, want %v", events, want)
+	}
+	if err := app.Shutdown(context.Background()); err == nil {
+		t.Error("Shutdown repetido debe devolver el mismo resultado")
+	}
+	if _, err := http.Get(base + "/readyz"); err == nil {
+		t.Error("el servidor sigue aceptando conexiones")
+	}
+}
+
+// writeTestCert genera un certificado ECDSA firmado por parent (autofirmado si
+// es nil) y lo guarda como dir/name.pem y dir/name-key.pem.
+func writeTestCert(t *testing.T, dir, name, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent, parentKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestApp_ShutdownDrainTimeout(t *testing.T) {
+	app := New(SetDrainTimeout(50*time.Millisecond), SetShutdownHookTimeout(time.Second))
+	hookErr := make(chan error, 1)
+	app.OnShutdown(func(ctx context.Context) error {
+		hookErr <- ctx.Err()
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("el hook debe tener un deadline")
+		}
+		return nil
+	})
+	entered := make(chan struct{})
+	app.Get("/slow", func(ctx *Context) {
+		close(entered)
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx, FromListener(ln)) }()
+	for i := 0; i < 100 && !app.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	<-entered
+	cancel()
+
+	// El drenado se vence, pero el hook recibe un contexto vigente
+	if err := <-runErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want DeadlineExceeded", err)
+	}
+	if err := <-hookErr; err != nil {
+		t.Errorf("ctx del hook: %v", err)
+	}
+}
+
+func TestApp_RunEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCert(t, dir, "ca", "ki-ca", nil, nil)
+	writeTestCert(t, dir, "server", "v1", ca, caKey)
+	writeTestCert(t, dir, "client", "cliente", ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := New(SetDrainTimeout(time.Second))
+	app.Get("/", func(ctx *Context) {
+		name := "anónimo"
+		if tlsState := ctx.Request.TLS; tlsState != nil && len(tlsState.PeerCertificates) > 0 {
+			name = tlsState.PeerCertificates[0].Subject.CommonName
+		}
+		ctx.Text(200, "public "+name)
+	})
+	admin := NewRoute(app)
+	admin.Get("/", func(ctx *Context) { ctx.Text(200, "admin") })
+
+	listen := func() net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ln
+	}
+	public, internal, secure := listen(), listen(), listen()
+	sock := filepath.Join(dir, "ki.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- app.Run(ctx,
+			FromListener(public),
+			FromListener(internal, WithHandler(admin)),
+			FromListener(secure,
+				WithTLS(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")),
+				TLSMinVersion(tls.VersionTLS13),
+				TLSClientAuth(filepath.Join(dir, "ca.pem")),
+				TLSReload(time.Millisecond),
+			),
+			UnixSocket(sock),
+		)
+	}()
+	for i := 0; i < 100 && !app.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, body := httpGet(t, "http://"+public.Addr().String()+"/"); body != "public anónimo" {
+		t.Errorf("public = %q", body)
+	}
+	if _, body := httpGet(t, "http://"+internal.Addr().String()+"/"); body != "admin" {
+		t.Errorf("admin = %q", body)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	if resp, err := unixClient.Get("http://unix/"); err != nil || resp.StatusCode != 200 {
+		t.Errorf("unix: %v %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	tlsGet := func(cfg *tls.Config) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, ForceAttemptHTTP2: true}}
+		return client.Get("https://" + secure.Addr().String() + "/")
+	}
+	resp, err := tlsGet(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "public cliente" || resp.ProtoMajor != 2 || resp.TLS.PeerCertificates[0].Subject.CommonName != "v1" {
+		t.Errorf("tls: %s %s %s", body, resp.Proto, resp.TLS.PeerCertificates[0].Subject.CommonName)
+	}
+	if _, err := tlsGet(&tls.Config{RootCAs: pool}); err == nil {
+		t.Error("sin certificado de cliente debe fallar")
+	}
+	if _, err := tlsGet(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}, MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Error("TLS 1.2 debe rechazarse")
+	}
+
+	// Certificado renovado en disco
+	writeTestCert(t, dir, "server", "v2", ca, caKey)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "server.pem"), later, later)
+	time.Sleep(5 * time.Millisecond)
+	resp, err = tlsGet(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "v2" {
+		t.Errorf("certificado recargado = %q", cn)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run = %v", err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("el socket debe borrarse al apagar: %v", err)
+	}
+}
+
+func TestApp_ServerTuning(t *testing.T) {
+	if err := enableH2C(&http.Server{}); err != nil {
+		t.Skip(err)
+	}
+	var configured *http.Server
+	app := New(
+		SetH2C(true),
+		SetHTTP2MaxConcurrentStreams(50),
+		SetHTTP2SendPingTimeout(time.Minute),
+		SetIdleTimeout(time.Minute),
+		SetReadHeaderTimeout(5*time.Second),
+		SetMaxHeaderBytes(8<<10),
+		SetServerConfig(func(srv *http.Server) { configured = srv }),
+	)
+	var states sync.Map
+	app.OnConnState(func(_ net.Conn, state http.ConnState) { states.Store(state, true) })
+	proto := func(ctx *Context) { ctx.Text(200, ctx.Request.Proto) }
+	app.Get("/", proto)
+	app.Post("/", proto)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx, FromListener(ln)) }()
+	for i := 0; i < 100 && !app.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if configured == nil || configured.IdleTimeout != time.Minute || configured.ReadHeaderTimeout != 5*time.Second ||
+		configured.MaxHeaderBytes != 8<<10 || configured.ConnState == nil {
+		t.Errorf("http.Server mal configurado: %+v", configured)
+	}
+
+	// HTTP/1.1 sigue funcionando en el mismo puerto
+	if _, body := httpGet(t, "http://"+ln.Addr().String()+"/"); body != "HTTP/1.1" {
+		t.Errorf("http/1.1 = %q", body)
+	}
+
+	readFrame := func(r io.Reader) (typ, flags byte, stream uint32, payload []byte, err error) {
+		hdr := make([]byte, 9)
+		if _, err = io.ReadFull(r, hdr); err != nil {
+			return
+		}
+		payload = make([]byte, int(hdr[0])<<16|int(hdr[1])<<8|int(hdr[2]))
+		_, err = io.ReadFull(r, payload)
+		return hdr[3], hdr[4], uint32(hdr[5]&0x7f)<<24 | uint32(hdr[6])<<16 | uint32(hdr[7])<<8 | uint32(hdr[8]), payload, err
+	}
+
+	// h2c con prior knowledge: el servidor responde al preface con SETTINGS,
+	// incluido SETTINGS_MAX_CONCURRENT_STREAMS
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	conn.Write([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"))
+	conn.Write([]byte{0, 0, 0, 0x4, 0, 0, 0, 0, 0}) // SETTINGS vacío
+	typ, _, _, payload, err := readFrame(conn)
+	if err != nil || typ != 0x4 || !bytes.Contains(payload, []byte{0, 0x3, 0, 0, 0, 50}) {
+		t.Errorf("h2c: frame %x %x, %v", typ, payload, err)
+	}
+	conn.Close()
+
+	// Upgrade desde HTTP/1.1: 101 y la respuesta llega por el stream 1
+	conn, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(conn, "GET /?q=1 HTTP/1.1\r\nHost: ki.test\r\nConnection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAoAAAAAIAAAAA\r\n\r\n")
+	br := bufio.NewReader(conn)
+	if line, _ := br.ReadString('\n'); line != "HTTP/1.1 101 Switching Protocols\r\n" {
+		t.Fatalf("upgrade = %q", line)
+	}
+	for line := "x"; line != "\r\n"; {
+		if line, err = br.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Write([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"))
+	conn.Write([]byte{0, 0, 0, 0x4, 0, 0, 0, 0, 0})
+	var headers bool
+	var body []byte
+	for {
+		typ, flags, stream, payload, err := readFrame(br)
+		if err != nil {
+			t.Fatalf("upgrade: %v (headers=%v body=%q)", err, headers, body)
+		}
+		if stream != 1 {
+			continue
+		}
+		headers = headers || typ == 0x1
+		if typ == 0x0 {
+			body = append(body, payload...)
+			if flags&0x1 != 0 {
+				break
+			}
+		}
+	}
+	if !headers || string(body) != "HTTP/2.0" {
+		t.Errorf("upgrade: headers=%v body=%q", headers, body)
+	}
+	conn.Close()
+
+	// Con cuerpo no hay Upgrade: se atiende en HTTP/1.1
+	req, _ := http.NewRequest("POST", "http://"+ln.Addr().String()+"/", strings.NewReader("x"))
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", "AAMAAABkAAQAoAAAAAIAAAAA")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(got) != "HTTP/1.1" {
+		t.Errorf("post upgrade: %d %q", resp.StatusCode, got)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run = %v", err)
+	}
+	for _, s := range []http.ConnState{http.StateNew, http.StateClosed} {
+		if _, ok := states.Load(s); !ok {
+			t.Errorf("OnConnState no recibió %s", s)
+		}
+	}
+}
+
+// =========== Benchmarks del router ============
+
+// benchRouter registra n recursos con 3 rutas cada uno (estática, parámetro, anidada).
+func benchRouter(n int) *App {
+	app := New()
+	h := func(ctx *Context) {}
+	for i := 0; i < n; i++ {
+		base := "/resource" + strconv.Itoa(i)
+		app.Get(base+"/list", h)
+		app.Get(base+"/:id([0-9]+)", h)
+		app.Get(base+"/:id/items/:item", h)
+	}
+	return app
+}
+
+// linearLookup reproduce el recorrido lineal que usaba el router antes del trie;
+// sirve sólo como referencia para los benchmarks.
+func linearLookup(routes []*route, method, path string) *route {
+	pathSeg := splitPath(path)
+	for _, rt := range routes {
+		if rt.isPrefix || (rt.method != "" && rt.method != method) || len(rt.segments) != len(pathSeg) {
+			continue
+		}
+		ok := true
+		for i, p := range rt.segments {
+			if isVarSegment(p) {
+				if _, expr := parseVarAndRegex(p); expr != "" && !regexp.MustCompile(expr).MatchString(pathSeg[i]) {
+					ok = false
+					break
+				}
+			} else if p != pathSeg[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return rt
+		}
+	}
+	return nil
+}
+
+func BenchmarkRouter_Lookup(b *testing.B) {
+	for _, n := range []int{10, 100, 200} {
+		app := benchRouter(n)
+		last := "/resource" + strconv.Itoa(n-1)
+		paths := []struct{ name, path string }{
+			{"static", last + "/list"},
+			{"param", last + "/42"},
+			{"nested", last + "/abc/items/7"},
+		}
+		for _, p := range paths {
+			name, path := p.name, p.path
+			b.Run(fmt.Sprintf("trie/routes=%d/%s", n*3, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if rt, _ := app.Router.lookup("GET", "", path, nil); rt == nil {
+						b.Fatal("route not found")
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("linear/routes=%d/%s", n*3, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if linearLookup(app.Router.routes, "GET", path) == nil {
+						b.Fatal("route not found")
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRouter_ServeHTTP(b *testing.B) {
+	app := benchRouter(200)
+	req := httptest.NewRequest("GET", "/resource199/42", nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		app.Router.ServeHTTP(w, req.Clone(req.Context()))
+	}
+}
+
+/**
+
+ go test -run xxx -bench=Router
+goos: linux
+goarch: amd64
+pkg: github.com/jad21/ki
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkRouter_Lookup/trie/routes=30/static         	 4540108	       266.6 ns/op
+BenchmarkRouter_Lookup/linear/routes=30/static       	 2260383	       545.5 ns/op
+BenchmarkRouter_Lookup/trie/routes=30/param          	  952186	      1119 ns/op
+BenchmarkRouter_Lookup/linear/routes=30/param        	  393177	      2983 ns/op
+BenchmarkRouter_Lookup/trie/routes=30/nested         	  802819	      1358 ns/op
+BenchmarkRouter_Lookup/linear/routes=30/nested       	 2315805	       528.9 ns/op
+BenchmarkRouter_Lookup/trie/routes=300/static        	 5736174	       258.6 ns/op
+BenchmarkRouter_Lookup/linear/routes=300/static      	  301088	      3629 ns/op
+BenchmarkRouter_Lookup/trie/routes=300/param         	 1154497	      1005 ns/op
+BenchmarkRouter_Lookup/linear/routes=300/param       	  187909	      5934 ns/op
+BenchmarkRouter_Lookup/trie/routes=300/nested        	  889230	      1384 ns/op
+BenchmarkRouter_Lookup/linear/routes=300/nested      	  338798	      3438 ns/op
+BenchmarkRouter_Lookup/trie/routes=600/static        	 4049487	       271.0 ns/op
+BenchmarkRouter_Lookup/linear/routes=600/static      	  194250	      5787 ns/op
+BenchmarkRouter_Lookup/trie/routes=600/param         	  973148	      1060 ns/op
+BenchmarkRouter_Lookup/linear/routes=600/param       	  114120	     10267 ns/op
+BenchmarkRouter_Lookup/trie/routes=600/nested        	  733126	      1663 ns/op
+BenchmarkRouter_Lookup/linear/routes=600/nested      	  186198	      6392 ns/op
+BenchmarkRouter_ServeHTTP                            	  149998	      7649 ns/op
+PASS
+*/
@@ -0,0 +1,229 @@
+package ki
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jad21/di"
+	"github.com/jad21/ki/env"
+)
+
+// LifecycleHook se ejecuta al iniciar o apagar la App. Se invoca con DI: puede
+// recibir context.Context y cualquier dependencia registrada, y devolver error.
+//
+//	app.OnShutdown(func(ctx context.Context, db *sql.DB) error {
+//		return db.Close()
+//	})
+type LifecycleHook any
+
+// OnStart registra un hook que Run ejecuta, en orden, antes de aceptar
+// peticiones. Si alguno falla Run no inicia el servidor.
+func (app *App) OnStart(fn LifecycleHook) {
+	app.onStart = append(app.onStart, fn)
+}
+
+// OnShutdown registra un hook que Shutdown ejecuta, en orden inverso, después
+// de drenar las conexiones. Los Modules lo usan para liberar sus recursos.
+func (app *App) OnShutdown(fn LifecycleHook) {
+	app.onShutdown = append(app.onShutdown, fn)
+}
+
+// Ready indica si la App está aceptando tráfico: true desde que Run inicia el
+// servidor hasta que empieza el apagado.
+func (app *App) Ready() bool {
+	return app.ready.Load()
+}
+
+// ReadinessHandler responde 200 mientras la App está lista y 503 desde que
+// empieza el apagado, para que el balanceador deje de enviar tráfico.
+//
+//	app.Get("/readyz", app.ReadinessHandler)
+func (app *App) ReadinessHandler(ctx *Context) {
+	if !app.Ready() {
+		ctx.Text(http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	ctx.Text(http.StatusOK, "ok")
+}
+
+// Run sirve en los endpoints indicados (por defecto :PORT), ejecuta los hooks
+// OnStart y bloquea hasta que ctx termine, llegue SIGINT/SIGTERM o falle algún
+// servidor; entonces hace Shutdown con el timeout de SetDrainTimeout. Devuelve
+// nil tras un apagado limpio.
+//
+//	app.Run(ctx,
+//		ki.Addr(":8080"),
+//		ki.AddrTLS(":8443", "cert.pem", "key.pem", ki.TLSReload(time.Minute)),
+//		ki.UnixSocket("/run/app.sock"),
+//	)
+func (app *App) Run(ctx context.Context, endpoints ...Endpoint) error {
+	if len(endpoints) == 0 {
+		endpoints = []Endpoint{Addr(":" + env.GetEnvVar("PORT", "5000"))}
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Se abren todos los listeners antes de iniciar para fallar sin servir a medias
+	type serving struct {
+		srv *http.Server
+		ln  net.Listener
+	}
+	var servers []serving
+	closeAll := func() {
+		for _, s := range servers {
+			s.ln.Close()
+		}
+	}
+	for _, e := range endpoints {
+		ln, tlsConfig, err := e.listen()
+		if err != nil {
+			closeAll()
+			return err
+		}
+		h := e.handler
+		if h == nil {
+			h = app.Router
+		}
+		srv, err := app.newServer(ProxyHeaders(LoggingHandler(h)), tlsConfig)
+		if err != nil {
+			ln.Close()
+			closeAll()
+			return err
+		}
+		servers = append(servers, serving{srv, ln})
+	}
+	for _, fn := range app.onStart {
+		if err := app.invokeHook(ctx, fn); err != nil {
+			closeAll()
+			return err
+		}
+	}
+
+	errc := make(chan error, len(servers))
+	app.serversMu.Lock()
+	for _, s := range servers {
+		app.servers = append(app.servers, s.srv)
+		log.Printf("go to %s", endpointURL(s.ln, s.srv.TLSConfig != nil))
+		go func(s serving) {
+			if s.srv.TLSConfig != nil {
+				errc <- s.srv.ServeTLS(s.ln, "", "")
+			} else {
+				errc <- s.srv.Serve(s.ln)
+			}
+		}(s)
+	}
+	app.serversMu.Unlock()
+	app.ready.Store(true)
+
+	var serveErr error
+	select {
+	case err := <-errc:
+		// ErrServerClosed: alguien llamó a Shutdown; se espera a que termine
+		if !errors.Is(err, http.ErrServerClosed) {
+			serveErr = err
+		}
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.shutdownDelay+app.drainTimeout)
+	defer cancel()
+	return errors.Join(serveErr, app.Shutdown(shutdownCtx))
+}
+
+// Shutdown apaga la App: marca Ready en false, cierra App.Done (para cortar
+// handlers de larga duración como SSE), espera SetShutdownDelay para que el
+// balanceador lo note, drena las conexiones hasta que ctx termine y ejecuta
+// los hooks OnShutdown con su propio timeout (SetShutdownHookTimeout).
+// Llamarlo de nuevo devuelve el mismo resultado.
+func (app *App) Shutdown(ctx context.Context) error {
+	app.shutdownOnce.Do(func() {
+		app.shutdownErr = app.shutdown(ctx)
+	})
+	return app.shutdownErr
+}
+
+func (app *App) shutdown(ctx context.Context) error {
+	app.ready.Store(false)
+	if app.stop != nil {
+		app.stop()
+	}
+	if app.shutdownDelay > 0 {
+		select {
+		case <-time.After(app.shutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	var errs []error
+	app.serversMu.Lock()
+	servers := app.servers
+	app.serversMu.Unlock()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			// Se venció el drenado: se cortan las conexiones que quedan
+			srv.Close()
+			errs = append(errs, err)
+		}
+	}
+	// Los hooks no heredan la cancelación del drenado, que puede haberse vencido
+	hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.hookTimeout)
+	defer cancel()
+	for i := len(app.onShutdown) - 1; i >= 0; i-- {
+		if err := app.invokeHook(hookCtx, app.onShutdown[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (app *App) invokeHook(ctx context.Context, fn LifecycleHook) error {
+	inj := di.New(app.DI)
+	inj.Map(ctx, di.WithInterface((*context.Context)(nil)))
+	return inj.InvokeWithErrorOnly(fn)
+}
+
+// OnConnState registra un hook para los cambios de estado de las conexiones
+// (http.Server.ConnState), por ejemplo para métricas de conexiones abiertas.
+func (app *App) OnConnState(fn func(conn net.Conn, state http.ConnState)) {
+	app.connState = append(app.connState, fn)
+}
+
+// newServer arma el http.Server con los ajustes de la App.
+func (app *App) newServer(h http.Handler, tlsConfig *tls.Config) (*http.Server, error) {
+	srv := &http.Server{
+		Handler:           h,
+		TLSConfig:         tlsConfig,
+		WriteTimeout:      app.WriteTimeout,
+		ReadTimeout:       app.ReadTimeout,
+		ReadHeaderTimeout: app.readHeaderTimeout,
+		IdleTimeout:       app.idleTimeout,
+		MaxHeaderBytes:    app.maxHeaderBytes,
+	}
+	if hooks := app.connState; len(hooks) > 0 {
+		srv.ConnState = func(conn net.Conn, state http.ConnState) {
+			for _, fn := range hooks {
+				fn(conn, state)
+			}
+		}
+	}
+	// Con TLS HTTP/2 ya se negocia por ALPN
+	if app.h2c && tlsConfig == nil {
+		if err := enableH2C(srv); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyHTTP2(srv, app.http2); err != nil {
+		return nil, err
+	}
+	if app.serverConfig != nil {
+		app.serverConfig(srv)
+	}
+	return srv, nil
+}
@@ -0,0 +1,280 @@
+package ki
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Endpoint es un punto de escucha de Run: dirección (o listener), TLS y el
+// Handler que atiende (por defecto el Router de la App).
+type Endpoint struct {
+	network string
+	addr    string
+	ln      net.Listener
+	handler http.Handler
+	tls     *tlsOptions
+}
+
+// EndpointOption configura un Endpoint.
+type EndpointOption func(e *Endpoint)
+
+// Addr escucha en una dirección TCP (":8080", "127.0.0.1:9090").
+func Addr(addr string, opts ...EndpointOption) Endpoint {
+	return newEndpoint(Endpoint{network: "tcp", addr: addr}, opts)
+}
+
+// AddrTLS escucha en una dirección TCP con TLS (HTTP/2 incluido).
+func AddrTLS(addr, certFile, keyFile string, opts ...EndpointOption) Endpoint {
+	return Addr(addr, append([]EndpointOption{WithTLS(certFile, keyFile)}, opts...)...)
+}
+
+// UnixSocket escucha en un socket Unix; si existe un socket viejo en path lo
+// reemplaza. El archivo se borra al apagar la App.
+func UnixSocket(path string, opts ...EndpointOption) Endpoint {
+	return newEndpoint(Endpoint{network: "unix", addr: path}, opts)
+}
+
+// FromListener sirve en un listener ya abierto (ej. systemd socket activation).
+func FromListener(ln net.Listener, opts ...EndpointOption) Endpoint {
+	return newEndpoint(Endpoint{ln: ln}, opts)
+}
+
+func newEndpoint(e Endpoint, opts []EndpointOption) Endpoint {
+	for _, opt := range opts {
+		opt(&e)
+	}
+	return e
+}
+
+// WithHandler atiende el endpoint con h en vez del Router de la App, por
+// ejemplo un puerto interno de administración:
+//
+//	admin := ki.NewRoute(app)
+//	admin.Get("/debug/vars", expvarHandler)
+//	app.Run(ctx, ki.Addr(":8080"), ki.Addr("127.0.0.1:9090", ki.WithHandler(admin)))
+func WithHandler(h http.Handler) EndpointOption {
+	return func(e *Endpoint) {
+		e.handler = h
+	}
+}
+
+// ----------- APP -----------
+
+// Listen sirve en addr hasta recibir SIGINT/SIGTERM (ver Run).
+func (app *App) Listen(addr string, opts ...EndpointOption) error {
+	return app.Run(context.Background(), Addr(addr, opts...))
+}
+
+// ListenTLS sirve en addr con TLS hasta recibir SIGINT/SIGTERM (ver Run).
+func (app *App) ListenTLS(addr, certFile, keyFile string, opts ...EndpointOption) error {
+	return app.Run(context.Background(), AddrTLS(addr, certFile, keyFile, opts...))
+}
+
+// ListenUnix sirve en el socket Unix path hasta recibir SIGINT/SIGTERM (ver Run).
+func (app *App) ListenUnix(path string, opts ...EndpointOption) error {
+	return app.Run(context.Background(), UnixSocket(path, opts...))
+}
+
+// Serve sirve en ln hasta recibir SIGINT/SIGTERM (ver Run).
+func (app *App) Serve(ln net.Listener, opts ...EndpointOption) error {
+	return app.Run(context.Background(), FromListener(ln, opts...))
+}
+
+// listen abre el listener del endpoint (con TLS si corresponde).
+func (e Endpoint) listen() (net.Listener, *tls.Config, error) {
+	ln := e.ln
+	if ln == nil {
+		if e.network == "unix" {
+			removeStaleSocket(e.addr)
+		}
+		var err error
+		if ln, err = net.Listen(e.network, e.addr); err != nil {
+			return nil, nil, err
+		}
+	}
+	if e.tls == nil {
+		return ln, nil, nil
+	}
+	cfg, err := e.tls.config()
+	if err != nil {
+		ln.Close()
+		return nil, nil, err
+	}
+	return ln, cfg, nil
+}
+
+func removeStaleSocket(path string) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		os.Remove(path)
+	}
+}
+
+func endpointURL(ln net.Listener, secure bool) string {
+	addr := ln.Addr()
+	if addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	if secure {
+		return "https://" + addr.String()
+	}
+	return "http://" + addr.String()
+}
+
+// ----------- TLS -----------
+
+type tlsOptions struct {
+	certFile, keyFile string
+	minVersion        uint16
+	clientCAFile      string
+	clientAuth        tls.ClientAuthType
+	reload            time.Duration
+	base              *tls.Config
+}
+
+// WithTLS sirve el endpoint con TLS usando los archivos PEM certFile y keyFile.
+func WithTLS(certFile, keyFile string) EndpointOption {
+	return func(e *Endpoint) {
+		t := e.tlsOptions()
+		t.certFile, t.keyFile = certFile, keyFile
+	}
+}
+
+// TLSMinVersion es la versión mínima de TLS (por defecto TLS 1.2).
+func TLSMinVersion(v uint16) EndpointOption {
+	return func(e *Endpoint) {
+		e.tlsOptions().minVersion = v
+	}
+}
+
+// TLSClientAuth exige certificados de cliente firmados por las CAs de caFile
+// (PEM). mode es opcional; por defecto tls.RequireAndVerifyClientCert.
+func TLSClientAuth(caFile string, mode ...tls.ClientAuthType) EndpointOption {
+	return func(e *Endpoint) {
+		t := e.tlsOptions()
+		t.clientCAFile = caFile
+		t.clientAuth = tls.RequireAndVerifyClientCert
+		if len(mode) > 0 {
+			t.clientAuth = mode[0]
+		}
+	}
+}
+
+// TLSReload vuelve a leer el certificado cuando cambian los archivos (ej. al
+// renovarlo cert-manager), revisándolos como mucho cada interval.
+func TLSReload(interval time.Duration) EndpointOption {
+	return func(e *Endpoint) {
+		e.tlsOptions().reload = interval
+	}
+}
+
+// TLSConfig es la configuración base; el resto de opciones TLS la completan.
+func TLSConfig(cfg *tls.Config) EndpointOption {
+	return func(e *Endpoint) {
+		e.tlsOptions().base = cfg
+	}
+}
+
+func (e *Endpoint) tlsOptions() *tlsOptions {
+	if e.tls == nil {
+		e.tls = &tlsOptions{}
+	}
+	return e.tls
+}
+
+func (t *tlsOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if t.base != nil {
+		cfg = t.base.Clone()
+	}
+	if t.minVersion != 0 {
+		cfg.MinVersion = t.minVersion
+	} else if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if t.certFile != "" {
+		cr := &certReloader{certFile: t.certFile, keyFile: t.keyFile, interval: t.reload}
+		if err := cr.load(); err != nil {
+			return nil, err
+		}
+		cfg.GetCertificate = cr.getCertificate
+	}
+	if cfg.GetCertificate == nil && len(cfg.Certificates) == 0 {
+		return nil, errors.New("ki: TLS sin certificado (usa WithTLS o TLSConfig)")
+	}
+	if t.clientCAFile != "" {
+		pem, err := os.ReadFile(t.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ki: %s no contiene certificados PEM", t.clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = t.clientAuth
+	}
+	return cfg, nil
+}
+
+// certReloader entrega el certificado y lo recarga si cambió la fecha de
+// modificación de los archivos. Si la recarga falla se sigue usando el anterior.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (cr *certReloader) load() error {
+	modTime, err := cr.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert, cr.modTime = &cert, modTime
+	return nil
+}
+
+func (cr *certReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.interval > 0 && time.Since(cr.checked) >= cr.interval {
+		cr.checked = time.Now()
+		if modTime, err := cr.lastModified(); err == nil && !modTime.Equal(cr.modTime) {
+			if err := cr.load(); err != nil {
+				log.Printf("ki: no se pudo recargar el certificado %s: %v", cr.certFile, err)
+			}
+		}
+	}
+	return cr.cert, nil
+}
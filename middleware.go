@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"reflect"
 	"time"
 )
 
@@ -17,12 +18,33 @@ func chainMiddlewares(final HandlerFunc, mws []Middleware) HandlerFunc {
 	// Encadena recursivamente
 	mw := mws[0]
 	next := chainMiddlewares(final, mws[1:])
-	return func(ctx *Context) {
+	own := returnsError(mw)
+	return func(ctx *Context) error {
+		var downstream error
 		ctx.next = func() error {
-			return dispatch(ctx, next)
+			downstream = dispatch(ctx, next)
+			return downstream
 		}
-		dispatch(ctx, mw)
+		err := dispatch(ctx, mw)
+		// Un middleware sin retorno (func(*Context)) no puede propagar el error
+		// del handler: se reporta igual. Si devuelve error, su resultado manda.
+		if !own {
+			return downstream
+		}
+		return err
+	}
+}
+
+// returnsError indica si el middleware devuelve un error propio.
+func returnsError(mw Middleware) bool {
+	switch mw.(type) {
+	case ctxErr:
+		return true
+	case fnEmpty, ctxOnly, wrReq, reqWr, ctxWrReq, ctxReqWr:
+		return false
 	}
+	t := reflect.TypeOf(mw)
+	return t != nil && t.Kind() == reflect.Func && t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
 }
 
 // LoggingHandler middleware
@@ -0,0 +1,206 @@
+package ki
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ------------- PARSEO DE PATRONES --------------
+//
+// Un segmento del patrón puede ser:
+//   - estático:   users
+//   - parámetro:  :id, :id([0-9]+), {id}, {id:[0-9]+}; opcional con ? (:slug?, {slug?})
+//     o con converter: {id:int}, {at:date} (ver Converter)
+//   - comodín:    *path, *path(regex), {path...}
+//   - mixto:      {year}-{month}.{format}, v{version:[0-9]+}
+
+// segmentKind clasifica los segmentos de un patrón.
+type segmentKind int
+
+const (
+	segStatic segmentKind = iota
+	segParam
+	segCatchAll
+	segMixed
+)
+
+type segment struct {
+	kind     segmentKind
+	name     string // segParam y segCatchAll
+	expr     string // regex inline
+	optional bool
+	parts    []segmentPart // segMixed
+}
+
+// segmentPart es un literal o una variable dentro de un segmento mixto.
+type segmentPart struct {
+	literal string
+	name    string
+	expr    string
+}
+
+// parseSegment clasifica un segmento del patrón.
+func parseSegment(seg string) segment {
+	switch {
+	case len(seg) > 1 && seg[0] == '*':
+		name, expr := parseVarAndRegex(seg[1:])
+		return segment{kind: segCatchAll, name: name, expr: expr}
+	case len(seg) > 5 && seg[0] == '{' && strings.HasSuffix(seg, "...}"):
+		return segment{kind: segCatchAll, name: seg[1 : len(seg)-4]}
+	case len(seg) > 1 && seg[0] == ':':
+		s := segment{kind: segParam}
+		if strings.HasSuffix(seg, "?") {
+			s.optional = true
+			seg = seg[:len(seg)-1]
+		}
+		s.name, s.expr = parseVarAndRegex(seg)
+		return s
+	case strings.Contains(seg, "{"):
+		parts, ok := parseBraces(seg)
+		if !ok {
+			break
+		}
+		if len(parts) == 1 && parts[0].name != "" {
+			s := segment{kind: segParam, name: parts[0].name, expr: parts[0].expr}
+			s.name, s.optional = strings.CutSuffix(s.name, "?")
+			return s
+		}
+		return segment{kind: segMixed, parts: parts}
+	}
+	return segment{kind: segStatic, name: seg}
+}
+
+// parseBraces separa literales y variables {name}, {name:regex} o {name(regex)}.
+func parseBraces(seg string) ([]segmentPart, bool) {
+	var parts []segmentPart
+	for len(seg) > 0 {
+		open := strings.IndexByte(seg, '{')
+		if open < 0 {
+			parts = append(parts, segmentPart{literal: seg})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, segmentPart{literal: seg[:open]})
+		}
+		// Busca la llave de cierre respetando las llaves de la regex ({4}, etc.)
+		depth, end := 0, -1
+		for i := open; i < len(seg) && end < 0; i++ {
+			switch seg[i] {
+			case '\\':
+				i++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return nil, false
+		}
+		inner := seg[open+1 : end]
+		var p segmentPart
+		if i := strings.IndexByte(inner, ':'); i >= 0 {
+			p.name, p.expr = inner[:i], inner[i+1:]
+		} else {
+			p.name, p.expr = parseVarAndRegex(inner)
+		}
+		parts = append(parts, p)
+		seg = seg[end+1:]
+	}
+	return parts, true
+}
+
+// varRegex devuelve la regex efectiva de una variable: RegexVar tiene prioridad sobre la inline.
+// Un converter ({id:int}) aporta su propia regex.
+func (rt *route) varRegex(name, expr string) string {
+	if re := rt.regexVars[name]; re != nil {
+		return re.String()
+	}
+	if c := rt.varConverter(name, expr); c != nil {
+		return c.Regex
+	}
+	return expr
+}
+
+// varConverter devuelve el converter de la variable, salvo que RegexVar la redefina.
+func (rt *route) varConverter(name, expr string) *Converter {
+	if expr == "" || rt.regexVars[name] != nil {
+		return nil
+	}
+	return rt.convs[expr]
+}
+
+// anchorRegex ancla la expresión para que coincida con el valor completo.
+func anchorRegex(expr string) string {
+	return "^(?:" + expr + ")$"
+}
+
+// segmentRegex devuelve la regex anclada efectiva del segmento ("" si no tiene).
+func (rt *route) segmentRegex(ps segment) string {
+	switch ps.kind {
+	case segMixed:
+		return rt.mixedRegex(ps.parts)
+	case segParam, segCatchAll:
+		if expr := rt.varRegex(ps.name, ps.expr); expr != "" {
+			return anchorRegex(expr)
+		}
+	}
+	return ""
+}
+
+// compileRegexes compila una sola vez, al registrar, las regex del patrón:
+// la de cada segmento y la de cada variable (usada por App.URL).
+func (rt *route) compileRegexes() error {
+	rt.regexes = make(map[string]*regexp.Regexp)
+	add := func(seg, expr string) error {
+		if expr == "" || rt.regexes[expr] != nil {
+			return nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("segmento %q: %w", seg, err)
+		}
+		rt.regexes[expr] = re
+		return nil
+	}
+	for _, seg := range rt.segments {
+		ps := parseSegment(seg)
+		if err := add(seg, rt.segmentRegex(ps)); err != nil {
+			return err
+		}
+		for _, p := range ps.parts {
+			if expr := rt.varRegex(p.name, p.expr); p.name != "" && expr != "" {
+				if err := add(seg, anchorRegex(expr)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mixedRegex compila un segmento mixto a una regex anclada con grupos con nombre.
+func (rt *route) mixedRegex(parts []segmentPart) string {
+	var b strings.Builder
+	b.WriteByte('^')
+	for _, p := range parts {
+		if p.name == "" {
+			b.WriteString(regexp.QuoteMeta(p.literal))
+			continue
+		}
+		expr := rt.varRegex(p.name, p.expr)
+		if expr == "" {
+			expr = ".+?"
+		}
+		b.WriteString("(?P<" + p.name + ">(?:" + expr + "))")
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
+func isVarSegment(p string) bool {
+	return len(p) > 0 && (p[0] == ':' || (len(p) > 1 && p[0] == '{' && p[len(p)-1] == '}'))
+}
@@ -0,0 +1,127 @@
+package ki
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// Problem es un "problem details" de RFC 9457. Extensions se serializa al
+// mismo nivel que los campos estándar.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// ProblemOptions configura UseProblemDetails.
+type ProblemOptions struct {
+	// TypeBase se antepone al Code del HTTPError para formar "type"
+	// (ej. https://api.example.com/problems/); sin él se usa "about:blank".
+	TypeBase string
+	// HTMLTemplate se renderiza con el *Problem cuando el cliente prefiere
+	// text/html (por defecto "error.html"). Si falla se responde JSON.
+	HTMLTemplate string
+}
+
+// UseProblemDetails hace que los errores sin OnError propio, los panics,
+// ctx.Fail y los 404/405 por defecto se respondan como application/problem+json:
+//
+//	{"type":"about:blank","title":"Unprocessable Entity","status":422,
+//	 "detail":"...","instance":"/users","errors":[{"field":"name",...}]}
+//
+// No reemplaza OnError: un handler propio sigue respondiendo y puede usar
+// ctx.Problem(err) para los casos que no maneja.
+func (app *App) UseProblemDetails(opts ProblemOptions) {
+	if opts.HTMLTemplate == "" {
+		opts.HTMLTemplate = "error.html"
+	}
+	app.problems = &opts
+}
+
+// NewProblem construye el Problem de err (ver ToHTTPError). Los errores de
+// validación y bind van en la extensión "errors"; los Details tipo map se
+// agregan como extensiones y el resto en "details".
+func NewProblem(err error, typeBase string) *Problem {
+	he := ToHTTPError(err)
+	p := &Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(he.Status),
+		Status:     he.Status,
+		Extensions: map[string]any{},
+	}
+	if he.Message != p.Title {
+		p.Detail = he.Message
+	}
+	if he.Code != "" {
+		p.Extensions["code"] = he.Code
+		if typeBase != "" {
+			p.Type = typeBase + he.Code
+		}
+	}
+	switch d := he.Details.(type) {
+	case nil:
+	case []FieldViolation, []FieldError:
+		p.Extensions["errors"] = d
+	case map[string]any:
+		for k, v := range d {
+			p.Extensions[k] = v
+		}
+	case H:
+		for k, v := range d {
+			p.Extensions[k] = v
+		}
+	case M:
+		for k, v := range d {
+			p.Extensions[k] = v
+		}
+	default:
+		p.Extensions["details"] = d
+	}
+	return p
+}
+
+// Problem responde err como problem details; si el cliente prefiere HTML y hay
+// TemplateEngine, renderiza el template configurado.
+func (s *Context) Problem(err error) {
+	opts := ProblemOptions{HTMLTemplate: "error.html"}
+	if s.App != nil && s.App.problems != nil {
+		opts = *s.App.problems
+	}
+	p := NewProblem(err, opts.TypeBase)
+	p.Instance = s.Request.URL.Path
+
+	offer := negotiate(s.Request.Header.Get("Accept"), "application/problem+json", "application/json", "text/html")
+	if offer == "text/html" && s.App != nil && s.App.TemplateEngine != nil {
+		var buf bytes.Buffer
+		if s.App.TemplateEngine.ExecuteTemplate(&buf, opts.HTMLTemplate, p) == nil {
+			s.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			s.Writer.WriteHeader(p.Status)
+			s.Writer.Write(buf.Bytes())
+			return
+		}
+	}
+	s.Writer.Header().Set("Content-Type", "application/problem+json")
+	s.Writer.WriteHeader(p.Status)
+	json.NewEncoder(s.Writer).Encode(p)
+}
@@ -1 +1,186 @@
 package ki
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Renderer escribe una respuesta en un media type. CanRender (opcional) indica
+// si puede representar data; si no, no participa en la negociación.
+type Renderer struct {
+	CanRender func(data any, o *RespondOptions) bool
+	Render    func(ctx *Context, code int, data any, o *RespondOptions) error
+}
+
+// RespondOptions son las opciones de ctx.Respond.
+type RespondOptions struct {
+	Template string   // template para text/html
+	Offers   []string // media types permitidos, en orden de preferencia
+}
+
+type RespondOption func(o *RespondOptions)
+
+// WithTemplate habilita text/html renderizando el template con data.
+func WithTemplate(name string) RespondOption {
+	return func(o *RespondOptions) {
+		o.Template = name
+	}
+}
+
+// Offer limita la respuesta a estos media types (el primero es el preferido).
+func Offer(mediaTypes ...string) RespondOption {
+	return func(o *RespondOptions) {
+		o.Offers = mediaTypes
+	}
+}
+
+type mediaRenderer struct {
+	mediaType string
+	*Renderer
+}
+
+// Renderers incluidos en cada App; el orden decide los empates de q.
+var defaultRenderers = []mediaRenderer{
+	{"application/json", &Renderer{
+		Render: func(ctx *Context, code int, data any, _ *RespondOptions) error {
+			return ctx.JSON(code, data)
+		},
+	}},
+	{"application/xml", &Renderer{
+		CanRender: func(data any, _ *RespondOptions) bool {
+			// encoding/xml no serializa maps
+			return data != nil && reflect.Indirect(reflect.ValueOf(data)).Kind() != reflect.Map
+		},
+		Render: func(ctx *Context, code int, data any, _ *RespondOptions) error {
+			return ctx.XML(code, data)
+		},
+	}},
+	{"text/html", &Renderer{
+		CanRender: func(_ any, o *RespondOptions) bool {
+			return o.Template != ""
+		},
+		Render: func(ctx *Context, code int, data any, o *RespondOptions) error {
+			ctx.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			return ctx.Render(code, o.Template, data)
+		},
+	}},
+	{"text/csv", &Renderer{
+		CanRender: func(data any, _ *RespondOptions) bool {
+			_, ok := data.([][]string)
+			return ok
+		},
+		Render: func(ctx *Context, code int, data any, _ *RespondOptions) error {
+			return ctx.CSV(code, data.([][]string))
+		},
+	}},
+	{"text/plain", &Renderer{
+		CanRender: func(data any, _ *RespondOptions) bool {
+			switch data.(type) {
+			case string, []byte, fmt.Stringer, error:
+				return true
+			}
+			return false
+		},
+		Render: func(ctx *Context, code int, data any, _ *RespondOptions) error {
+			ctx.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			var body string
+			switch v := data.(type) {
+			case []byte:
+				body = string(v)
+			case error:
+				body = v.Error()
+			default:
+				body = fmt.Sprint(v)
+			}
+			ctx.Text(code, body)
+			return nil
+		},
+	}},
+}
+
+// RegisterRenderer agrega (o reemplaza) el renderer de un media type.
+//
+//	app.RegisterRenderer("application/msgpack", &ki.Renderer{
+//		Render: func(ctx *ki.Context, code int, data any, _ *ki.RespondOptions) error {
+//			ctx.Writer.Header().Set("Content-Type", "application/msgpack")
+//			ctx.Writer.WriteHeader(code)
+//			return msgpack.NewEncoder(ctx.Writer).Encode(data)
+//		},
+//	})
+func (app *App) RegisterRenderer(mediaType string, r *Renderer) {
+	mediaType = strings.ToLower(mediaType)
+	for i, mr := range app.renderers {
+		if mr.mediaType == mediaType {
+			app.renderers[i].Renderer = r
+			return
+		}
+	}
+	app.renderers = append(app.renderers, mediaRenderer{mediaType, r})
+}
+
+// Negotiate devuelve el media type preferido por el Accept entre offers
+// ("" si ninguno es aceptable) y agrega Vary: Accept.
+func (s *Context) Negotiate(offers ...string) string {
+	s.Writer.Header().Add("Vary", "Accept")
+	return negotiate(s.Request.Header.Get("Accept"), offers...)
+}
+
+// Respond elige el renderer según el header Accept (con q-values) entre los
+// registrados en la App que pueden representar data. Si ninguno es aceptable
+// responde 406 y devuelve nil.
+//
+//	ctx.Respond(200, user)                              // JSON, XML...
+//	ctx.Respond(200, user, ki.WithTemplate("user.html")) // también HTML
+func (s *Context) Respond(code int, data any, opts ...RespondOption) error {
+	o := &RespondOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	renderers := defaultRenderers
+	if s.App != nil && s.App.renderers != nil {
+		renderers = s.App.renderers
+	}
+
+	byType := make(map[string]*Renderer, len(renderers))
+	var offers []string
+	add := func(mr mediaRenderer) {
+		if mr.CanRender == nil || mr.CanRender(data, o) {
+			byType[mr.mediaType] = mr.Renderer
+			offers = append(offers, mr.mediaType)
+		}
+	}
+	if len(o.Offers) > 0 {
+		for _, mt := range o.Offers {
+			for _, mr := range renderers {
+				if mr.mediaType == strings.ToLower(mt) {
+					add(mr)
+				}
+			}
+		}
+	} else {
+		for _, mr := range renderers {
+			add(mr)
+		}
+	}
+
+	mt := s.Negotiate(offers...)
+	if mt == "" {
+		he := NewHTTPError(http.StatusNotAcceptable, "").WithDetails(M{"available": offers})
+		if s.App != nil && s.App.problems != nil {
+			s.Problem(he)
+		} else {
+			http.Error(s.Writer, he.Message+": "+strings.Join(offers, ", "), he.Status)
+		}
+		return nil
+	}
+	return byType[mt].Render(s, code, data, o)
+}
+
+// NegotiatedResults es un ResultRenderer que usa ctx.Respond:
+//
+//	app.RenderResults(ki.NegotiatedResults)
+func NegotiatedResults(ctx *Context, code int, v any) error {
+	return ctx.Respond(code, v)
+}
@@ -24,11 +24,21 @@ type RouteBuilder struct {
 
 	cacheConf *cachePolicy
 
+	// Límites del cuerpo (nil/0 = los de la App o por defecto)
+	bodyLimit *bodyLimit
+	maxMemory int64
+
+	// Nombre para App.URL y última ruta registrada por este builder
+	name  string
+	route *route
+	err   error
+
 	// Hooks y handlers avanzados
-	onError    func(ctx *Context, err error)
-	notFound   func(ctx *Context)
-	beforeEach func(ctx *Context)
-	afterEach  func(ctx *Context)
+	onError          func(ctx *Context, err error)
+	notFound         func(ctx *Context)
+	methodNotAllowed func(ctx *Context)
+	beforeEach       func(ctx *Context)
+	afterEach        func(ctx *Context)
 }
 
 // ========== CONSTRUCTOR PRINCIPAL ==========
@@ -80,11 +90,50 @@ func (rb *RouteBuilder) RegexVar(varName, pattern string) *RouteBuilder {
 	rb.regexVars[varName] = regexp.MustCompile(pattern)
 	return rb
 }
+
+// Name asigna un nombre a la ruta para construir URLs con App.URL.
+// Puede llamarse antes o después de registrar el handler.
+func (rb *RouteBuilder) Name(name string) *RouteBuilder {
+	if rb.route != nil {
+		rb.router.setName(rb.route, name)
+		return rb
+	}
+	rb.name = name
+	return rb
+}
 func (rb *RouteBuilder) Cache(duration time.Duration) *RouteBuilder {
 	rb.cacheConf = &cachePolicy{duration: duration}
 	return rb
 }
 
+// BodyLimit limita el cuerpo de las peticiones de la ruta y reemplaza el
+// límite heredado del grupo o de la App (ver ki.BodyLimit).
+func (rb *RouteBuilder) BodyLimit(max int64, opts ...BodyLimitOption) *RouteBuilder {
+	rb.bodyLimit = newBodyLimit(max, opts)
+	return rb
+}
+
+// MaxBodySize limita el cuerpo de la petición a n bytes (http.MaxBytesReader),
+// conservando la velocidad mínima heredada. Al superarlo el error responde 413.
+func (rb *RouteBuilder) MaxBodySize(n int64) *RouteBuilder {
+	l := bodyLimit{}
+	if rb.bodyLimit != nil {
+		l = *rb.bodyLimit
+	} else if rb.app != nil && rb.app.bodyLimit != nil {
+		l = *rb.app.bodyLimit
+	}
+	l.max = n
+	rb.bodyLimit = &l
+	return rb
+}
+
+// MaxMemory es cuánto de un cuerpo multipart se guarda en memoria; el resto
+// va a archivos temporales (por defecto 32 MiB).
+func (rb *RouteBuilder) MaxMemory(n int64) *RouteBuilder {
+	rb.maxMemory = n
+	return rb
+}
+
 // ========== HOOKS Y HANDLERS DE ERROR/NOTFOUND ==========
 
 func (rb *RouteBuilder) OnError(fn func(ctx *Context, err error)) *RouteBuilder {
@@ -95,6 +144,10 @@ func (rb *RouteBuilder) NotFound(fn func(ctx *Context)) *RouteBuilder {
 	rb.notFound = fn
 	return rb
 }
+func (rb *RouteBuilder) MethodNotAllowed(fn func(ctx *Context)) *RouteBuilder {
+	rb.methodNotAllowed = fn
+	return rb
+}
 func (rb *RouteBuilder) BeforeEach(fn func(ctx *Context)) *RouteBuilder {
 	rb.beforeEach = fn
 	return rb
@@ -141,13 +194,7 @@ func (rb *RouteBuilder) Static(dir string) *RouteBuilder {
 	if rb.path == "" && rb.prefix == "" {
 		panic("Debes usar Path o PathPrefix antes de Static")
 	}
-	fileServer := http.FileServer(http.Dir(dir))
-	handler := http.Handler(fileServer)
-	// Si se usa PathPrefix, aplica StripPrefix automáticamente
-	if rb.prefix != "" {
-		handler = http.StripPrefix(rb.prefix, fileServer)
-	}
-	rb.router.addRouteAdvanced(rb, handler)
+	rb.router.addRouteAdvanced(rb, rb.staticHandler(http.Dir(dir)))
 	return rb
 }
 
@@ -156,13 +203,30 @@ func (rb *RouteBuilder) StaticFS(fs fs.FS) *RouteBuilder {
 	if rb.path == "" && rb.prefix == "" {
 		panic("Debes usar Path o PathPrefix antes de StaticFS")
 	}
-	fileServer := http.FileServer(http.FS(fs))
-	handler := http.Handler(fileServer)
+	rb.router.addRouteAdvanced(rb, rb.staticHandler(http.FS(fs)))
+	return rb
+}
+
+// staticHandler sirve archivos de fsys. Con PathPrefix aplica StripPrefix
+// automáticamente; con un comodín final (/assets/*path) sirve el valor capturado.
+func (rb *RouteBuilder) staticHandler(fsys http.FileSystem) HandlerFunc {
+	fileServer := http.FileServer(fsys)
 	if rb.prefix != "" {
-		handler = http.StripPrefix(rb.prefix, fileServer)
+		return http.StripPrefix(rb.prefix, fileServer)
 	}
-	rb.router.addRouteAdvanced(rb, handler)
-	return rb
+	segments := splitPattern(rb.path)
+	if len(segments) > 0 {
+		if ps := parseSegment(segments[len(segments)-1]); ps.kind == segCatchAll {
+			name := ps.name
+			return func(ctx *Context) {
+				r := ctx.Request.Clone(ctx.Request.Context())
+				r.URL.Path = "/" + ctx.Vars()[name]
+				r.URL.RawPath = ""
+				fileServer.ServeHTTP(ctx.Writer, r)
+			}
+		}
+	}
+	return fileServer
 }
 
 // ========== ANIDAMIENTO DE GRUPOS Y PREFIJOS ==========
@@ -170,19 +234,22 @@ func (rb *RouteBuilder) StaticFS(fs fs.FS) *RouteBuilder {
 func (rb *RouteBuilder) Group(path string, fn ...func(r Router)) *GroupRouter {
 	child := &GroupRouter{
 		RouteBuilder: &RouteBuilder{
-			app:        rb.app,
-			router:     rb.router,
-			parent:     rb,
-			path:       rb.path + path,
-			domain:     rb.domain,
-			mws:        append([]Middleware{}, rb.mws...),
-			headers:    copyMap(rb.headers),
-			regexVars:  copyRegex(rb.regexVars),
-			cacheConf:  rb.cacheConf,
-			onError:    rb.onError,
-			notFound:   rb.notFound,
-			beforeEach: rb.beforeEach,
-			afterEach:  rb.afterEach,
+			app:              rb.app,
+			router:           rb.router,
+			parent:           rb,
+			path:             rb.path + path,
+			domain:           rb.domain,
+			mws:              append([]Middleware{}, rb.mws...),
+			headers:          copyMap(rb.headers),
+			regexVars:        copyRegex(rb.regexVars),
+			cacheConf:        rb.cacheConf,
+			bodyLimit:        rb.bodyLimit,
+			maxMemory:        rb.maxMemory,
+			onError:          rb.onError,
+			notFound:         rb.notFound,
+			methodNotAllowed: rb.methodNotAllowed,
+			beforeEach:       rb.beforeEach,
+			afterEach:        rb.afterEach,
 		},
 		basePath: rb.path + path,
 	}
@@ -194,19 +261,22 @@ func (rb *RouteBuilder) Group(path string, fn ...func(r Router)) *GroupRouter {
 func (rb *RouteBuilder) PathPrefixGroup(prefix string, fn ...func(r Router)) *GroupRouter {
 	child := &GroupRouter{
 		RouteBuilder: &RouteBuilder{
-			app:        rb.app,
-			router:     rb.router,
-			parent:     rb,
-			prefix:     rb.prefix + prefix,
-			domain:     rb.domain,
-			mws:        append([]Middleware{}, rb.mws...),
-			headers:    copyMap(rb.headers),
-			regexVars:  copyRegex(rb.regexVars),
-			cacheConf:  rb.cacheConf,
-			onError:    rb.onError,
-			notFound:   rb.notFound,
-			beforeEach: rb.beforeEach,
-			afterEach:  rb.afterEach,
+			app:              rb.app,
+			router:           rb.router,
+			parent:           rb,
+			prefix:           rb.prefix + prefix,
+			domain:           rb.domain,
+			mws:              append([]Middleware{}, rb.mws...),
+			headers:          copyMap(rb.headers),
+			regexVars:        copyRegex(rb.regexVars),
+			cacheConf:        rb.cacheConf,
+			bodyLimit:        rb.bodyLimit,
+			maxMemory:        rb.maxMemory,
+			onError:          rb.onError,
+			notFound:         rb.notFound,
+			methodNotAllowed: rb.methodNotAllowed,
+			beforeEach:       rb.beforeEach,
+			afterEach:        rb.afterEach,
 		},
 		basePath: rb.prefix + prefix,
 	}
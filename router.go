@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +16,7 @@ type Middleware interface{}
 type (
 	fnEmpty  = func()
 	ctxOnly  = func(*Context)
+	ctxErr   = func(*Context) error
 	wrReq    = func(http.ResponseWriter, *http.Request)
 	reqWr    = func(*http.Request, http.ResponseWriter)
 	ctxWrReq = func(*Context, http.ResponseWriter, *http.Request)
@@ -38,6 +40,8 @@ type Router interface {
 // ------------- ESTRUCTURA INTERNA DE LA RUTA --------------
 
 type route struct {
+	name        string
+	source      string // file:line donde se registró
 	method      string
 	pattern     string
 	segments    []string
@@ -50,12 +54,20 @@ type route struct {
 	domain    string
 	headers   map[string]string
 	regexVars map[string]*regexp.Regexp
+	regexes   map[string]*regexp.Regexp // regex anclada -> compilada (al registrar)
+	convs     map[string]*Converter     // converters de la App
+	cache     *cachePolicy
+
+	// Límites del cuerpo
+	bodyLimit *bodyLimit
+	maxMemory int64
 
 	// Hooks y handlers
-	onError    func(ctx *Context, err error)
-	notFound   func(ctx *Context)
-	beforeEach func(ctx *Context)
-	afterEach  func(ctx *Context)
+	onError          func(ctx *Context, err error)
+	notFound         func(ctx *Context)
+	methodNotAllowed func(ctx *Context)
+	beforeEach       func(ctx *Context)
+	afterEach        func(ctx *Context)
 }
 
 // Router principal
@@ -63,12 +75,24 @@ type router struct {
 	routes      []*route
 	middlewares []Middleware
 	app         *App
+
+	// Árboles por dominio y método ("" = cualquiera)
+	trees map[string]map[string]*routeTree
+	// Rutas con nombre para App.URL
+	names map[string]*route
+	// Forma normalizada -> primera ruta de cada método, para detectar conflictos
+	shapes map[string][]*route
 }
 
 // ---------- CONSTRUCTOR ----------
 
 func NewRoute(app *App) *router {
-	return &router{app: app}
+	return &router{
+		app:    app,
+		trees:  make(map[string]map[string]*routeTree),
+		names:  make(map[string]*route),
+		shapes: make(map[string][]*route),
+	}
 }
 
 // ----------- PIPELINE ----------
@@ -145,35 +169,64 @@ func (r *router) addRouteAdvanced(rb *RouteBuilder, handler HandlerFunc) {
 	// fmt.Printf("[DEBUG] Registrando ruta %-20s | middlewares globales: %-2d | builder: %-2d | total: %-2d\n", pattern, globalCount, builderCount, totalCount)
 
 	rt := &route{
-		method:      rb.method,
-		pattern:     pattern,
-		segments:    segments,
-		prefix:      rb.prefix,
-		isPrefix:    isPrefix,
-		handler:     handler,
-		middlewares: mws,
-		domain:      rb.domain,
-		headers:     copyMap(rb.headers),
-		regexVars:   copyRegex(rb.regexVars),
-		onError:     rb.onError,
-		notFound:    rb.notFound,
-		beforeEach:  rb.beforeEach,
-		afterEach:   rb.afterEach,
+		method:           rb.method,
+		pattern:          pattern,
+		segments:         segments,
+		prefix:           rb.prefix,
+		isPrefix:         isPrefix,
+		handler:          handler,
+		middlewares:      mws,
+		domain:           rb.domain,
+		headers:          copyMap(rb.headers),
+		regexVars:        copyRegex(rb.regexVars),
+		cache:            rb.cacheConf,
+		bodyLimit:        rb.bodyLimit,
+		maxMemory:        rb.maxMemory,
+		onError:          rb.onError,
+		notFound:         rb.notFound,
+		methodNotAllowed: rb.methodNotAllowed,
+		beforeEach:       rb.beforeEach,
+		afterEach:        rb.afterEach,
+		source:           callerLocation(),
+	}
+	if r.app != nil {
+		rt.convs = r.app.converters
+	}
+	if err := rt.compileRegexes(); err != nil {
+		panic(fmt.Sprintf("ki: regex inválida en la ruta %s %s (%s): %v", methodLabel(rt.method), rt.pattern, rt.source, err))
+	}
+	rb.err = nil
+	if err := r.checkConflict(rt); err != nil {
+		r.reportConflict(rb, err)
 	}
 	r.routes = append(r.routes, rt)
+	r.insert(rt)
 
-	// Sort alphabetically by path: fix: las que tienen parámetros, este de ultimo
-	sort.Slice(r.routes, func(i, j int) bool {
-		left := strings.Join(r.routes[i].segments, "/")
-		right := strings.Join(r.routes[j].segments, "/")
-
-		if r.routes[i].isPrefix && r.routes[j].isPrefix {
-			// En los isPrefix se ordena al inverso.
-			return left > right
-		}
+	// El nombre aplica sólo a la ruta registrada; Name posterior usa rb.route
+	rb.route = rt
+	if rb.name != "" {
+		r.setName(rt, rb.name)
+		rb.name = ""
+	}
+}
 
-		return left < right
-	})
+// insert agrega la ruta al árbol de su dominio y método.
+func (r *router) insert(rt *route) {
+	methods := r.trees[rt.domain]
+	if methods == nil {
+		methods = make(map[string]*routeTree)
+		r.trees[rt.domain] = methods
+	}
+	tree := methods[rt.method]
+	if tree == nil {
+		tree = newRouteTree()
+		methods[rt.method] = tree
+	}
+	if rt.isPrefix {
+		tree.prefixes.insert(rt.prefix, rt)
+		return
+	}
+	tree.root.insert(rt.segments, rt)
 }
 
 // ----------- LEGACY PATHPREFIX -----------
@@ -186,7 +239,35 @@ func (r *router) AddPathPrefix(prefix string, handler http.Handler) {
 }
 
 // ----------- MATCHING Y PIPELINE -----------
+// splitPattern divide un patrón en segmentos; las barras dentro de () o {}
+// pertenecen a la regex y no separan segmentos.
 func splitPattern(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return []string{}
+	}
+	var segments []string
+	depth, start := 0, 0
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				segments = append(segments, p[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, p[start:])
+}
+
+// splitPath divide el path de la petición en segmentos.
+func splitPath(p string) []string {
 	p = strings.TrimPrefix(p, "/")
 	if p == "" {
 		return []string{}
@@ -199,59 +280,18 @@ func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	method := req.Method
 	host := req.Host
 
-	var matched *route
-	var params map[string]string
-
-	// 1. Matching exacto
-	for _, rt := range r.routes {
-		if rt.isPrefix {
-			continue
-		}
-		if rt.domain != "" && rt.domain != host {
-			continue
-		}
-		if rt.method != "" && method != rt.method {
-			continue
-		}
-		vars, ok := matchSegmentsWithRegex(rt.segments, splitPattern(path), rt.regexVars)
-		if !ok {
-			continue
-		}
-		if !matchHeaders(rt.headers, req.Header) {
-			continue
-		}
-		matched = rt
-		params = vars
-		break
-	}
-	// 2. Matching por prefijo
-	if matched == nil {
-		for _, rt := range r.routes {
-			if !rt.isPrefix {
-				continue
-			}
-			if rt.domain != "" && rt.domain != host {
-				continue
-			}
-			if rt.method != "" && method != rt.method {
-				continue
-			}
-			if !strings.HasPrefix(path, rt.prefix) {
-				continue
-			}
-			if !matchHeaders(rt.headers, req.Header) {
-				continue
-			}
-			vars, ok := matchSegmentsWithRegex(rt.segments, splitPattern(path), rt.regexVars)
-			if !ok {
-				vars = map[string]string{}
-			}
-			matched = rt
-			params = vars
-			break
+	// 1. Matching: exacto primero, luego por prefijo
+	matched, params := r.lookup(method, host, path, req.Header)
+	if matched == nil && method == http.MethodHead && r.app.autoHead {
+		// HEAD automático: se usa la ruta GET descartando el cuerpo
+		if matched, params = r.lookup(http.MethodGet, host, path, req.Header); matched != nil {
+			hw := &headResponseWriter{ResponseWriter: w}
+			defer hw.finish()
+			w = hw
 		}
 	}
-	// 3. Not found/Handler de error
+
+	// 2. Not found/Method not allowed
 	if matched == nil {
 		app := r.app
 		ctx, err := UseContext(app, w, req)
@@ -259,20 +299,56 @@ func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if allowed, rt := r.allowedMethods(host, path, req.Header); len(allowed) > 0 {
+			w.Header().Set("Allow", r.allowHeader(allowed))
+			if method == http.MethodOptions && app.autoOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if rt.methodNotAllowed != nil {
+				rt.methodNotAllowed(ctx)
+			} else if app.notAllowed != nil {
+				app.notAllowed(ctx)
+			} else if app.problems != nil {
+				ctx.Problem(NewHTTPError(http.StatusMethodNotAllowed, ""))
+			} else {
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			}
+			return
+		}
 		if app.notFound != nil {
 			app.notFound(ctx)
 			return
 		}
+		if app.problems != nil {
+			ctx.Problem(NewHTTPError(http.StatusNotFound, ""))
+			return
+		}
 		http.NotFound(w, req)
 		return
 	}
-	// 4. Ejecuta pipeline
+	// 3. Ejecuta pipeline
+	// El límite del cuerpo se aplica antes de que UseContext lea el formulario
+	limit := matched.bodyLimit
+	if limit == nil {
+		limit = r.app.bodyLimit
+	}
+	// Un converter que falló se reporta antes de tocar el cuerpo
+	reqErr := paramError(params)
+	if reqErr == nil {
+		reqErr = limit.apply(w, req)
+	}
 	ctx, err := UseContext(r.app, w, req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	ctx.params = params
+	ctx.route = matched
+	ctx.setParams(params)
+	if reqErr != nil {
+		r.handleError(ctx, matched, reqErr, reqErr.Error())
+		return
+	}
 	if matched.beforeEach != nil {
 		matched.beforeEach(ctx)
 	} else if r.app.before != nil {
@@ -280,6 +356,7 @@ func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	handler := chainMiddlewares(matched.handler, matched.middlewares)
 	defer func() {
+		ctx.runFinish()
 		if matched.afterEach != nil {
 			matched.afterEach(ctx)
 		} else if r.app.after != nil {
@@ -293,53 +370,126 @@ func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			default:
 				err = fmt.Errorf("%v", rec)
 			}
-			if matched.onError != nil {
-				matched.onError(ctx, err)
-			} else if r.app.onError != nil {
-				r.app.onError(ctx, err)
-			} else {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			}
+			r.handleError(ctx, matched, err, "Internal Server Error")
 		}
 	}()
 	if err := dispatch(ctx, handler); err != nil {
-		if matched.onError != nil {
-			matched.onError(ctx, err)
-		} else if r.app.onError != nil {
-			r.app.onError(ctx, err)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		r.handleError(ctx, matched, err, err.Error())
+	}
+}
+
+// handleError aplica los ErrorMapper y luego el OnError de la ruta, el de la
+// App o la respuesta por defecto (message es el texto para errores no HTTP).
+func (r *router) handleError(ctx *Context, rt *route, err error, message string) {
+	err = r.app.mapError(err)
+	if rt.onError != nil {
+		rt.onError(ctx, err)
+	} else if r.app.onError != nil {
+		r.app.onError(ctx, err)
+	} else {
+		defaultError(ctx, err, message)
 	}
 }
 
 // ----------- MATCHING AVANZADO -----------
 
-func matchSegmentsWithRegex(patternSeg, pathSeg []string, regexVars map[string]*regexp.Regexp) (map[string]string, bool) {
-	if len(patternSeg) != len(pathSeg) {
-		return nil, false
-	}
-	params := map[string]string{}
-	for i := 0; i < len(patternSeg); i++ {
-		p := patternSeg[i]
-		s := pathSeg[i]
-		if len(p) > 0 && (p[0] == ':' || (len(p) > 1 && p[0] == '{' && p[len(p)-1] == '}')) {
-			varName, varRegex := parseVarAndRegex(p)
-			if varRegex != "" {
-				re := regexVars[varName]
-				if re == nil {
-					re = regexp.MustCompile(varRegex)
-				}
-				if !re.MatchString(s) {
-					return nil, false
-				}
+// lookup busca la ruta para la petición. Se prueba primero el dominio exacto y
+// luego las rutas sin dominio; dentro de cada uno, el método exacto y luego
+// las rutas sin método. Las rutas exactas siempre ganan a los prefijos.
+func (r *router) lookup(method, host, path string, h http.Header) (*route, []pathParam) {
+	trees, n := r.candidateTrees(method, host)
+	segments := splitPath(path)
+	var ps []pathParam
+	for _, t := range trees[:n] {
+		if rt := t.root.lookup(segments, h, &ps); rt != nil {
+			return rt, ps
+		}
+	}
+	for _, t := range trees[:n] {
+		if rt := t.prefixes.lookup(path, h); rt != nil {
+			return rt, nil
+		}
+	}
+	return nil, nil
+}
+
+// allowedMethods devuelve los métodos registrados para el path (ordenados) y la
+// primera ruta encontrada, usada para resolver el handler de 405 por scope.
+func (r *router) allowedMethods(host, path string, h http.Header) ([]string, *route) {
+	var allowed []string
+	var first *route
+	segments := splitPath(path)
+	domains := [2]string{host, ""}
+	for i, d := range domains {
+		if i == 1 && host == "" {
+			break
+		}
+		for method, t := range r.trees[d] {
+			if method == "" || containsString(allowed, method) {
+				continue
+			}
+			var ps []pathParam
+			rt := t.root.lookup(segments, h, &ps)
+			if rt == nil {
+				rt = t.prefixes.lookup(path, h)
+			}
+			if rt == nil {
+				continue
 			}
-			params[varName] = s
-		} else if p != s {
-			return nil, false
+			allowed = append(allowed, method)
+			if first == nil || rt.method < first.method {
+				first = rt
+			}
+		}
+	}
+	sort.Strings(allowed)
+	return allowed, first
+}
+
+// allowHeader agrega al listado los métodos que el router responde automáticamente.
+func (r *router) allowHeader(allowed []string) string {
+	if r.app.autoHead && containsString(allowed, http.MethodGet) && !containsString(allowed, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if r.app.autoOptions && !containsString(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.Strings(allowed)
+	return strings.Join(allowed, ", ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *router) candidateTrees(method, host string) (trees [4]*routeTree, n int) {
+	domains := [2]string{host, ""}
+	for i, d := range domains {
+		if i == 1 && host == "" {
+			break
+		}
+		methods := r.trees[d]
+		if methods == nil {
+			continue
+		}
+		if t := methods[method]; t != nil {
+			trees[n] = t
+			n++
+		}
+		if method == "" {
+			continue
+		}
+		if t := methods[""]; t != nil {
+			trees[n] = t
+			n++
 		}
 	}
-	return params, true
+	return trees, n
 }
 
 func parseVarAndRegex(segment string) (string, string) {
@@ -374,6 +524,8 @@ func dispatch(ctx *Context, h HandlerFunc) error {
 	case ctxOnly:
 		fn(ctx)
 		return nil
+	case ctxErr:
+		return fn(ctx)
 
 	case wrReq:
 		fn(w, r)
@@ -393,6 +545,62 @@ func dispatch(ctx *Context, h HandlerFunc) error {
 
 	default:
 		// Recurre al inyector sólo cuando hace falta reflexión real
-		return ctx.injector.InvokeWithErrorOnly(h)
+		return invokeHandler(ctx, h)
+	}
+}
+
+// ----------- HEAD AUTOMÁTICO -----------
+
+// headResponseWriter descarta el cuerpo de una ruta GET servida como HEAD,
+// conservando los headers y calculando Content-Length si el handler no lo fijó.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	sent   bool // Flush ya envió los headers (SSE, streams)
+}
+
+func (w *headResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += len(b)
+	return len(b), nil
+}
+
+// Flush envía los headers, así ctx.SSE y los streams también responden HEAD.
+func (w *headResponseWriter) Flush() {
+	if !w.sent {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.sent = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap permite a http.ResponseController llegar al writer original (deadlines).
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headResponseWriter) finish() {
+	if w.sent {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Length") == "" && w.size > 0 {
+		h.Set("Content-Length", strconv.Itoa(w.size))
 	}
+	w.ResponseWriter.WriteHeader(w.status)
 }
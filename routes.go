@@ -0,0 +1,138 @@
+package ki
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RouteInfo describe una ruta registrada, tal como quedó tras combinar grupos,
+// prefijos, dominios y headers.
+type RouteInfo struct {
+	Name        string
+	Method      string // "" = cualquier método
+	Pattern     string
+	Prefix      bool
+	Domain      string
+	Headers     map[string]string
+	RegexVars   map[string]string // variable -> regex (RegexVar o inline)
+	Middlewares []string
+	Cache       time.Duration
+	Handler     string
+}
+
+// Routes devuelve las rutas registradas en orden de registro.
+func (app *App) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(app.Router.routes))
+	for _, rt := range app.Router.routes {
+		infos = append(infos, rt.info())
+	}
+	return infos
+}
+
+// PrintRoutes escribe la tabla de rutas de la App en w.
+func (app *App) PrintRoutes(w io.Writer) error {
+	return FprintRoutes(w, app.Routes())
+}
+
+// FprintRoutes escribe las rutas como una tabla alineada.
+func FprintRoutes(w io.Writer, routes []RouteInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tKIND\tNAME\tDOMAIN\tHEADERS\tMW\tCACHE\tHANDLER")
+	for _, ri := range routes {
+		method := ri.Method
+		if method == "" {
+			method = "ANY"
+		}
+		kind := "exact"
+		if ri.Prefix {
+			kind = "prefix"
+		}
+		cache := "-"
+		if ri.Cache > 0 {
+			cache = ri.Cache.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			method, ri.Pattern, kind, dash(ri.Name), dash(ri.Domain),
+			dash(joinPairs(ri.Headers)), strconv.Itoa(len(ri.Middlewares)), cache, ri.Handler)
+	}
+	return tw.Flush()
+}
+
+func (rt *route) info() RouteInfo {
+	ri := RouteInfo{
+		Name:      rt.name,
+		Method:    rt.method,
+		Pattern:   rt.pattern,
+		Prefix:    rt.isPrefix,
+		Domain:    rt.domain,
+		Headers:   copyMap(rt.headers),
+		RegexVars: make(map[string]string),
+		Handler:   funcName(rt.handler),
+	}
+	for _, seg := range rt.segments {
+		ps := parseSegment(seg)
+		if ps.expr != "" {
+			ri.RegexVars[ps.name] = ps.expr
+		}
+		for _, p := range ps.parts {
+			if p.expr != "" {
+				ri.RegexVars[p.name] = p.expr
+			}
+		}
+	}
+	for name, re := range rt.regexVars {
+		ri.RegexVars[name] = re.String()
+	}
+	for _, mw := range rt.middlewares {
+		ri.Middlewares = append(ri.Middlewares, funcName(mw))
+	}
+	if rt.cache != nil {
+		ri.Cache = rt.cache.duration
+	}
+	return ri
+}
+
+// ----------- HELPERS -----------
+
+// funcName devuelve el nombre de la función (paquete.Func) o el tipo si no es función.
+func funcName(fn any) string {
+	if fn == nil {
+		return ""
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", fn)
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return v.Type().String()
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func joinPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
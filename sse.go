@@ -0,0 +1,215 @@
+package ki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errSSEClosed = errors.New("ki: el stream SSE está cerrado")
+
+// SSEEvent es un evento de Server-Sent Events. Data se escribe tal cual si es
+// string o []byte y como JSON en cualquier otro caso.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  any
+	Retry time.Duration
+}
+
+// SSEWriter escribe eventos text/event-stream y hace flush tras cada uno.
+// Es seguro usarlo desde varias goroutines.
+type SSEWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	ctx         context.Context
+	lastEventID string
+	stop        chan struct{}
+	closed      bool
+}
+
+// SSE inicia un stream de Server-Sent Events: fija los headers, desactiva la
+// caché de la ruta y envía la respuesta 200. Falla si el writer no soporta flush.
+//
+//	app.Get("/jobs/:id/events", func(ctx *ki.Context) error {
+//		sse, err := ctx.SSE()
+//		if err != nil {
+//			return err
+//		}
+//		sse.Heartbeat(15 * time.Second)
+//		for p := range progress(sse.LastEventID()) {
+//			if err := sse.Send(ki.SSEEvent{ID: p.ID, Event: "progress", Data: p}); err != nil {
+//				return nil // el cliente se desconectó
+//			}
+//		}
+//		return nil
+//	})
+func (s *Context) SSE() (*SSEWriter, error) {
+	skipCache(s.Writer)
+	h := s.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+
+	rc := http.NewResponseController(s.Writer)
+	s.Writer.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("ki: SSE requiere un ResponseWriter con Flush: %w", err)
+	}
+	clearWriteDeadline(rc)
+	// El stream también termina cuando la App empieza a apagarse
+	ctx, cancel := context.WithCancel(s.Request.Context())
+	if s.App != nil {
+		stop := context.AfterFunc(s.App, cancel)
+		s.onFinish(func() { stop() })
+	}
+	s.onFinish(cancel)
+	sw := &SSEWriter{
+		w:           s.Writer,
+		rc:          rc,
+		ctx:         ctx,
+		lastEventID: s.Request.Header.Get("Last-Event-ID"),
+		stop:        make(chan struct{}),
+	}
+	// Al terminar el handler no se puede seguir escribiendo (ej. el heartbeat)
+	s.onFinish(sw.Close)
+	return sw, nil
+}
+
+// clearWriteDeadline quita el deadline de SetWriteTimeout: una respuesta en
+// streaming dura lo que el cliente siga conectado. Si el writer no soporta
+// deadlines no hace nada.
+func clearWriteDeadline(rc *http.ResponseController) {
+	_ = rc.SetWriteDeadline(time.Time{})
+}
+
+// LastEventID es el header Last-Event-ID con el que el cliente reconectó.
+func (sw *SSEWriter) LastEventID() string {
+	return sw.lastEventID
+}
+
+// Done se cierra cuando el cliente se desconecta, el contexto se cancela o la
+// App empieza a apagarse.
+func (sw *SSEWriter) Done() <-chan struct{} {
+	return sw.ctx.Done()
+}
+
+// Event envía un evento con nombre.
+func (sw *SSEWriter) Event(name string, data any) error {
+	return sw.Send(SSEEvent{Event: name, Data: data})
+}
+
+// Data envía un evento sin nombre ("message" en el navegador).
+func (sw *SSEWriter) Data(data any) error {
+	return sw.Send(SSEEvent{Data: data})
+}
+
+// Retry indica al navegador cuánto esperar antes de reconectar.
+func (sw *SSEWriter) Retry(d time.Duration) error {
+	return sw.Send(SSEEvent{Retry: d})
+}
+
+// Comment envía un comentario (lo ignora el navegador; útil como keep-alive).
+func (sw *SSEWriter) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": " + line + "\n")
+	}
+	b.WriteString("\n")
+	return sw.write(b.String())
+}
+
+// Send escribe el evento completo.
+func (sw *SSEWriter) Send(e SSEEvent) error {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + singleLine(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + singleLine(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if e.Data != nil {
+		var data string
+		switch v := e.Data.(type) {
+		case string:
+			data = v
+		case []byte:
+			data = string(v)
+		default:
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			data = string(raw)
+		}
+		for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return sw.write(b.String())
+}
+
+// Heartbeat envía un comentario cada interval hasta que el cliente se
+// desconecte o se llame a Close.
+func (sw *SSEWriter) Heartbeat(interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if sw.Comment("ping") != nil {
+					return
+				}
+			case <-sw.ctx.Done():
+				return
+			case <-sw.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close detiene el heartbeat y descarta las escrituras siguientes. Se llama
+// automáticamente cuando el handler retorna.
+func (sw *SSEWriter) Close() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.closed {
+		sw.closed = true
+		close(sw.stop)
+	}
+}
+
+func (sw *SSEWriter) write(s string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return errSSEClosed
+	}
+	if err := sw.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := sw.w.Write([]byte(s)); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+// singleLine evita que un id o nombre de evento inyecte campos nuevos.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
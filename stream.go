@@ -0,0 +1,235 @@
+package ki
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Por defecto los streams hacen flush cada streamFlushRows elementos o cada
+// streamFlushInterval, lo que ocurra primero.
+const (
+	streamFlushRows     = 1000
+	streamFlushInterval = 500 * time.Millisecond
+)
+
+// Stream llama a fn hasta que devuelva false o el cliente se desconecte,
+// haciendo flush tras cada llamada. La respuesta no se cachea.
+//
+//	ctx.Stream(func(w io.Writer) bool {
+//		row, ok := <-rows
+//		if ok {
+//			fmt.Fprintln(w, row)
+//		}
+//		return ok
+//	})
+func (s *Context) Stream(fn func(w io.Writer) bool) error {
+	skipCache(s.Writer)
+	done := s.Request.Context()
+	rc := http.NewResponseController(s.Writer)
+	clearWriteDeadline(rc)
+	for {
+		if err := done.Err(); err != nil {
+			return err
+		}
+		more := fn(s.Writer)
+		if err := rc.Flush(); err != nil && err != http.ErrNotSupported {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// streamFlusher hace flush periódico y corta al desconectarse el cliente.
+type streamFlusher struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	done    context.Context
+	every   int
+	pending int
+	last    time.Time
+	closed  bool
+}
+
+func newStreamFlusher(s *Context, code int, contentType string) *streamFlusher {
+	skipCache(s.Writer)
+	s.Writer.Header().Set("Content-Type", contentType)
+	s.Writer.Header().Del("Content-Length")
+	s.Writer.WriteHeader(code)
+	rc := http.NewResponseController(s.Writer)
+	clearWriteDeadline(rc)
+	return &streamFlusher{
+		w:     s.Writer,
+		rc:    rc,
+		done:  s.Request.Context(),
+		every: streamFlushRows,
+		last:  time.Now(),
+	}
+}
+
+// check se llama antes de escribir cada elemento.
+func (f *streamFlusher) check() error {
+	if f.closed {
+		return io.ErrClosedPipe
+	}
+	return f.done.Err()
+}
+
+// tick cuenta un elemento escrito y hace flush si corresponde.
+func (f *streamFlusher) tick() error {
+	f.pending++
+	if f.pending >= f.every || time.Since(f.last) >= streamFlushInterval {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Flush envía al cliente lo escrito hasta ahora.
+func (f *streamFlusher) Flush() error {
+	f.pending = 0
+	f.last = time.Now()
+	if err := f.rc.Flush(); err != nil && err != http.ErrNotSupported {
+		return err
+	}
+	return nil
+}
+
+// FlushEvery cambia cada cuántos elementos se hace flush.
+func (f *streamFlusher) FlushEvery(n int) {
+	if n > 0 {
+		f.every = n
+	}
+}
+
+// ----------- CSV -----------
+
+// CSVStream escribe filas CSV sin acumularlas en memoria.
+type CSVStream struct {
+	*streamFlusher
+	cw *csv.Writer
+}
+
+// StreamCSV envía el status y, si header no es nil, la fila de encabezado.
+// El stream se cierra solo al terminar el handler.
+//
+//	out, err := ctx.StreamCSV(200, []string{"id", "name"})
+//	for rows.Next() {
+//		if err := out.Write([]string{id, name}); err != nil {
+//			return err // cliente desconectado
+//		}
+//	}
+//	return out.Close()
+func (s *Context) StreamCSV(code int, header []string) (*CSVStream, error) {
+	cs := &CSVStream{streamFlusher: newStreamFlusher(s, code, "text/csv; charset=utf-8")}
+	cs.cw = csv.NewWriter(cs.w)
+	s.onFinish(func() { cs.Close() })
+	if header != nil {
+		if err := cs.cw.Write(header); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+// Write agrega una fila.
+func (cs *CSVStream) Write(row []string) error {
+	if err := cs.check(); err != nil {
+		return err
+	}
+	if err := cs.cw.Write(row); err != nil {
+		return err
+	}
+	return cs.tick()
+}
+
+// Flush envía las filas pendientes.
+func (cs *CSVStream) Flush() error {
+	cs.cw.Flush()
+	if err := cs.cw.Error(); err != nil {
+		return err
+	}
+	return cs.streamFlusher.Flush()
+}
+
+func (cs *CSVStream) tick() error {
+	cs.pending++
+	if cs.pending >= cs.every || time.Since(cs.last) >= streamFlushInterval {
+		return cs.Flush()
+	}
+	return nil
+}
+
+// Close envía las filas pendientes; después Write falla.
+func (cs *CSVStream) Close() error {
+	if cs.closed {
+		return nil
+	}
+	cs.closed = true
+	return cs.Flush()
+}
+
+// ----------- JSON -----------
+
+// JSONStream escribe valores JSON uno a uno, como array o NDJSON.
+type JSONStream struct {
+	*streamFlusher
+	array bool
+	count int
+}
+
+// StreamJSONArray escribe un array JSON elemento a elemento; Close (o el fin
+// del handler) escribe el "]" final.
+func (s *Context) StreamJSONArray(code int) (*JSONStream, error) {
+	js := &JSONStream{streamFlusher: newStreamFlusher(s, code, "application/json; charset=utf-8"), array: true}
+	s.onFinish(func() { js.Close() })
+	if _, err := io.WriteString(js.w, "["); err != nil {
+		return nil, err
+	}
+	return js, nil
+}
+
+// StreamNDJSON escribe un valor JSON por línea (application/x-ndjson).
+func (s *Context) StreamNDJSON(code int) (*JSONStream, error) {
+	js := &JSONStream{streamFlusher: newStreamFlusher(s, code, "application/x-ndjson")}
+	s.onFinish(func() { js.Close() })
+	return js, nil
+}
+
+// Write agrega un valor.
+func (js *JSONStream) Write(v any) error {
+	if err := js.check(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if js.array && js.count > 0 {
+		data = append([]byte{','}, data...)
+	}
+	// El "\n" separa líneas en NDJSON y es válido dentro del array
+	if _, err := js.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	js.count++
+	return js.tick()
+}
+
+// Close termina el array (si corresponde) y hace flush; después Write falla.
+func (js *JSONStream) Close() error {
+	if js.closed {
+		return nil
+	}
+	js.closed = true
+	if js.array {
+		if _, err := io.WriteString(js.w, "]\n"); err != nil {
+			return err
+		}
+	}
+	return js.Flush()
+}
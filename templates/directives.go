@@ -2,6 +2,7 @@ package templates
 
 import (
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"regexp"
 	"strings"
@@ -28,6 +29,26 @@ func FuncMapDefault() template.FuncMap {
 		"max":        maxFunc,
 		"formatDate": formatDate,
 		"dic":        dict,
+		"url":        URLFunc(nil),
+	}
+}
+
+// URLResolver construye la URL de una ruta con nombre a partir de pares clave/valor.
+type URLResolver func(name string, params ...string) (string, error)
+
+// URLFunc adapta un URLResolver a una función de template; los valores se
+// convierten a string con fmt.Sprint.
+// Uso en template: {{ url "user.show" "id" .ID }}
+func URLFunc(resolve URLResolver) func(name string, params ...any) (string, error) {
+	return func(name string, params ...any) (string, error) {
+		if resolve == nil {
+			return "", fmt.Errorf("url: no hay rutas registradas para resolver %q", name)
+		}
+		values := make([]string, len(params))
+		for i, p := range params {
+			values[i] = fmt.Sprint(p)
+		}
+		return resolve(name, values...)
 	}
 }
 
@@ -2,6 +2,7 @@ package templates
 
 import (
 	"html/template"
+	"strings"
 	"testing"
 	"time"
 )
@@ -263,3 +264,21 @@ func TestFormatDate(t *testing.T) {
 		})
 	}
 }
+
+// TestURLFunc verifica la función "url" con y sin resolver.
+func TestURLFunc(t *testing.T) {
+	t.Parallel()
+	if _, err := URLFunc(nil)("home"); err == nil {
+		t.Error("Se esperaba error sin resolver")
+	}
+	fn := URLFunc(func(name string, params ...string) (string, error) {
+		return "/" + name + "/" + strings.Join(params, "/"), nil
+	})
+	got, err := fn("user", "id", 42)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	if got != "/user/id/42" {
+		t.Errorf("Se esperaba %q, se obtuvo %q", "/user/id/42", got)
+	}
+}
@@ -151,6 +151,13 @@ func (r *Registry) Funcs(funcMap template.FuncMap) *template.Template {
 	// return r
 }
 
+// SetURLResolver registra el resolver usado por la función "url" de los templates.
+func (r *Registry) SetURLResolver(resolve URLResolver) {
+	fn := URLFunc(resolve)
+	r.FuncMap["url"] = fn
+	r.Base.Funcs(template.FuncMap{"url": fn})
+}
+
 // -------------------- helpers -------------------------
 
 // Detecta si es un componente con {{define Name}}
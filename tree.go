@@ -0,0 +1,307 @@
+package ki
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ------------- ÁRBOL DE RUTAS (TRIE) --------------
+//
+// Cada combinación dominio+método tiene su propio routeTree. Las rutas exactas
+// viven en un trie por segmentos y las rutas PathPrefix en un radix tree
+// comprimido por caracteres (el prefijo se compara con strings.HasPrefix).
+//
+// Prioridad al resolver: estático > parámetro con regex > parámetro simple >
+// comodín (*path, {path...}) > prefijo.
+
+type routeTree struct {
+	root     *node
+	prefixes *prefixNode
+}
+
+func newRouteTree() *routeTree {
+	return &routeTree{root: &node{}, prefixes: &prefixNode{}}
+}
+
+// node es un nodo del trie por segmentos.
+type node struct {
+	static    map[string]*node
+	params    []*node // regex primero, luego simples (orden de registro)
+	catchAlls []*node // comodines; sólo pueden tener rutas, no hijos
+
+	key    string // segmento normalizado: nombre + regex
+	name   string
+	re     *regexp.Regexp
+	conv   *Converter
+	names  []string     // variables de un segmento mixto
+	groups []int        // índice del grupo de cada variable en re
+	convs  []*Converter // converter de cada variable del segmento mixto
+	routes []*route
+}
+
+// pathParam es un par nombre/valor capturado durante el matching.
+type pathParam struct {
+	key       string
+	value     string
+	converted any   // valor del converter, si lo hay
+	err       error // el converter falló: se responde 400 con un ParamError
+}
+
+func (n *node) insert(segments []string, rt *route) {
+	optional := false
+	for i, seg := range segments {
+		ps := parseSegment(seg)
+		if ps.kind == segCatchAll && i != len(segments)-1 {
+			panic(fmt.Sprintf("ki: el comodín %q debe ser el último segmento en %q", seg, rt.pattern))
+		}
+		if ps.optional {
+			// La ruta también termina antes de cada segmento opcional
+			n.routes = appendRoute(n.routes, rt)
+			optional = true
+		} else if optional {
+			panic(fmt.Sprintf("ki: el segmento %q sigue a uno opcional en %q", seg, rt.pattern))
+		}
+		n = n.child(ps, rt)
+	}
+	n.routes = appendRoute(n.routes, rt)
+}
+
+// child devuelve (o crea) el hijo correspondiente al segmento del patrón.
+func (n *node) child(ps segment, rt *route) *node {
+	if ps.kind == segStatic {
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+		c := n.static[ps.name]
+		if c == nil {
+			c = &node{key: ps.name}
+			n.static[ps.name] = c
+		}
+		return c
+	}
+
+	expr := rt.segmentRegex(ps)
+	var key string
+	switch ps.kind {
+	case segMixed:
+		key = "~" + expr
+		for _, p := range ps.parts {
+			if c := rt.varConverter(p.name, p.expr); c != nil {
+				key += ":" + p.name + "=" + p.expr
+			}
+		}
+	case segCatchAll:
+		key = "*" + ps.name + "(" + expr + ")"
+	default:
+		key = ps.name + "(" + expr + ")"
+	}
+	conv := rt.varConverter(ps.name, ps.expr)
+	if conv != nil {
+		key += ":" + ps.expr
+	}
+	list := &n.params
+	if ps.kind == segCatchAll {
+		list = &n.catchAlls
+	}
+	for _, c := range *list {
+		if c.key == key {
+			return c
+		}
+	}
+	c := &node{key: key, name: ps.name, re: rt.regexes[expr], conv: conv}
+	for _, p := range ps.parts {
+		if p.name != "" {
+			c.names = append(c.names, p.name)
+			c.groups = append(c.groups, c.re.SubexpIndex(p.name))
+			c.convs = append(c.convs, rt.varConverter(p.name, p.expr))
+		}
+	}
+	// Los que tienen regex van antes que los simples
+	i := len(*list)
+	if c.re != nil {
+		for i = 0; i < len(*list) && (*list)[i].re != nil; i++ {
+		}
+	}
+	*list = append(*list, nil)
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = c
+	return c
+}
+
+// lookup recorre el trie con backtracking y devuelve la primera ruta válida.
+func (n *node) lookup(segments []string, h http.Header, ps *[]pathParam) *route {
+	if len(segments) == 0 {
+		return pickRoute(n.routes, h)
+	}
+	seg := segments[0]
+	if c := n.static[seg]; c != nil {
+		if rt := c.lookup(segments[1:], h, ps); rt != nil {
+			return rt
+		}
+	}
+	for _, c := range n.params {
+		mark := len(*ps)
+		if !c.capture(seg, ps) {
+			*ps = (*ps)[:mark]
+			continue
+		}
+		if rt := c.lookup(segments[1:], h, ps); rt != nil {
+			return rt
+		}
+		*ps = (*ps)[:mark]
+	}
+	if len(n.catchAlls) > 0 {
+		tail := strings.Join(segments, "/")
+		for _, c := range n.catchAlls {
+			mark := len(*ps)
+			if !c.capture(tail, ps) {
+				*ps = (*ps)[:mark]
+				continue
+			}
+			if rt := pickRoute(c.routes, h); rt != nil {
+				return rt
+			}
+			*ps = (*ps)[:mark]
+		}
+	}
+	return nil
+}
+
+// capture valida el valor contra la regex y los converters del nodo y agrega
+// las variables capturadas a ps.
+func (c *node) capture(value string, ps *[]pathParam) bool {
+	if len(c.names) > 0 {
+		// Segmento mixto: una variable por grupo de la regex
+		m := c.re.FindStringSubmatch(value)
+		if m == nil {
+			return false
+		}
+		for i, name := range c.names {
+			*ps = append(*ps, convertParam(name, m[c.groups[i]], c.convs[i]))
+		}
+		return true
+	}
+	if c.re != nil && !c.re.MatchString(value) {
+		return false
+	}
+	*ps = append(*ps, convertParam(c.name, value, c.conv))
+	return true
+}
+
+// convertParam aplica el converter. Si la regex coincidió pero Convert falla
+// (ej. un int fuera de rango) la ruta coincide igual y el error se reporta
+// como 400, el mismo resultado que ctx.ParamInt sobre un :id.
+func convertParam(name, value string, conv *Converter) pathParam {
+	p := pathParam{key: name, value: value}
+	if conv == nil {
+		return p
+	}
+	p.converted, p.err = conv.Convert(value)
+	return p
+}
+
+// paramError devuelve el primer converter que falló, si lo hay.
+func paramError(ps []pathParam) error {
+	for _, p := range ps {
+		if p.err != nil {
+			return &ParamError{Name: p.key, Value: p.value, Err: p.err}
+		}
+	}
+	return nil
+}
+
+// prefixNode es un nodo del radix tree de prefijos.
+type prefixNode struct {
+	path     string
+	children []*prefixNode
+	routes   []*route
+}
+
+func (n *prefixNode) insert(key string, rt *route) {
+	for {
+		if key == "" {
+			n.routes = appendRoute(n.routes, rt)
+			return
+		}
+		var next *prefixNode
+		idx := -1
+		for i, c := range n.children {
+			if c.path[0] == key[0] {
+				next, idx = c, i
+				break
+			}
+		}
+		if next == nil {
+			n.children = append(n.children, &prefixNode{path: key, routes: []*route{rt}})
+			return
+		}
+		l := commonPrefixLen(key, next.path)
+		if l < len(next.path) {
+			// Divide el nodo existente en el punto común
+			split := &prefixNode{path: next.path[:l], children: []*prefixNode{next}}
+			next.path = next.path[l:]
+			n.children[idx] = split
+			next = split
+		}
+		n = next
+		key = key[l:]
+	}
+}
+
+// lookup devuelve la ruta del prefijo más largo que coincide con path.
+func (n *prefixNode) lookup(path string, h http.Header) *route {
+	var best *route
+	for {
+		if rt := pickRoute(n.routes, h); rt != nil {
+			best = rt
+		}
+		if path == "" {
+			return best
+		}
+		var next *prefixNode
+		for _, c := range n.children {
+			if strings.HasPrefix(path, c.path) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return best
+		}
+		path = path[len(next.path):]
+		n = next
+	}
+}
+
+// ----------- HELPERS DEL ÁRBOL -----------
+
+// appendRoute mantiene primero las rutas con más restricciones de headers.
+func appendRoute(routes []*route, rt *route) []*route {
+	i := len(routes)
+	for i > 0 && len(routes[i-1].headers) < len(rt.headers) {
+		i--
+	}
+	routes = append(routes, nil)
+	copy(routes[i+1:], routes[i:])
+	routes[i] = rt
+	return routes
+}
+
+func pickRoute(routes []*route, h http.Header) *route {
+	for _, rt := range routes {
+		if matchHeaders(rt.headers, h) {
+			return rt
+		}
+	}
+	return nil
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,157 @@
+package ki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen es lo que mira http.DetectContentType.
+const sniffLen = 512
+
+// FormFile devuelve el primer archivo del campo name. Si se pasa allow, el
+// tipo se detecta por contenido (no por extensión ni por el Content-Type del
+// cliente) y debe coincidir con alguno ("image/png", "image/*"); si no, 415.
+//
+//	fh, err := ctx.FormFile("avatar", "image/png", "image/jpeg")
+//	if err != nil {
+//		return err // 400 si falta, 413 si supera MaxBodySize, 415 si el tipo no está permitido
+//	}
+//	return ctx.SaveUploadedFile(fh, filepath.Join("uploads", id+".png"))
+func (s *Context) FormFile(name string, allow ...string) (*multipart.FileHeader, error) {
+	files, err := s.FormFiles(name, allow...)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// FormFiles devuelve todos los archivos del campo name (ver FormFile).
+func (s *Context) FormFiles(name string, allow ...string) ([]*multipart.FileHeader, error) {
+	form, err := s.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, BadRequest(fmt.Sprintf("falta el archivo %q", name)).WithCause(http.ErrMissingFile)
+	}
+	if len(allow) > 0 {
+		for _, fh := range files {
+			f, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, _, err = SniffContentType(f, allow...)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// SaveUploadedFile copia el archivo subido a dst, creando los directorios
+// que falten. Si la copia falla no deja un archivo a medias.
+func (s *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+// MultipartReader lee el cuerpo parte por parte, sin archivos temporales, para
+// enviar los archivos directo a su destino (ej. un bucket). No se puede
+// combinar con FormFile ni con Bind en la misma petición.
+//
+//	mr, err := ctx.MultipartReader()
+//	for {
+//		part, err := mr.NextPart()
+//		if err == io.EOF {
+//			break
+//		}
+//		body, ct, err := ki.SniffContentType(part, "application/pdf")
+//		if err != nil {
+//			return err
+//		}
+//		bucket.Put(part.FileName(), ct, body)
+//	}
+func (s *Context) MultipartReader() (*multipart.Reader, error) {
+	mr, err := s.Request.MultipartReader()
+	if err != nil {
+		return nil, uploadError(err)
+	}
+	return mr, nil
+}
+
+// multipartForm parsea el cuerpo una sola vez con el MaxMemory de la ruta. Los
+// archivos temporales se borran al terminar la petición.
+func (s *Context) multipartForm() (*multipart.Form, error) {
+	r := s.Request
+	if r.MultipartForm != nil {
+		return r.MultipartForm, nil
+	}
+	maxMemory := int64(defaultMaxMemory)
+	if s.route != nil && s.route.maxMemory > 0 {
+		maxMemory = s.route.maxMemory
+	}
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return nil, uploadError(err)
+	}
+	form := r.MultipartForm
+	s.onFinish(func() { form.RemoveAll() })
+	return form, nil
+}
+
+func uploadError(err error) error {
+	if errors.Is(err, http.ErrNotMultipart) || errors.Is(err, http.ErrMissingBoundary) {
+		return BadRequest("se esperaba un cuerpo multipart/form-data").WithCause(err)
+	}
+	return err
+}
+
+// SniffContentType detecta el tipo de r por su contenido y, si se pasa allow,
+// devuelve un 415 cuando no coincide con ninguno. El reader devuelto entrega
+// el contenido completo, incluidos los bytes ya leídos.
+func SniffContentType(r io.Reader, allow ...string) (io.Reader, string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	head = head[:n]
+	ct := http.DetectContentType(head)
+	if len(allow) > 0 {
+		mt, _, _ := mime.ParseMediaType(ct)
+		if quality(parseAccept(strings.Join(allow, ",")), mt) <= 0 {
+			return nil, ct, NewHTTPError(http.StatusUnsupportedMediaType, "tipo de archivo no permitido: "+mt).
+				WithCode("unsupported_media_type").
+				WithDetails(H{"type": mt, "allowed": allow})
+		}
+	}
+	return io.MultiReader(bytes.NewReader(head), r), ct, nil
+}
@@ -0,0 +1,129 @@
+package ki
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jad21/ki/templates"
+)
+
+// urlResolverSetter lo implementan los motores de plantillas que exponen la
+// función "url" (ej. templates.Registry).
+type urlResolverSetter interface {
+	SetURLResolver(templates.URLResolver)
+}
+
+// URL construye el path de la ruta con nombre. Los params son pares clave/valor:
+// las claves que son variables del patrón se sustituyen en el path (validando su
+// regex) y el resto se agregan como query string.
+//
+//	app.Get("/user/:id([0-9]+)", h).Name("user.show")
+//	app.URL("user.show", "id", "42", "tab", "posts") // /user/42?tab=posts
+func (app *App) URL(name string, params ...string) (string, error) {
+	return app.Router.URL(name, params...)
+}
+
+func (r *router) URL(name string, params ...string) (string, error) {
+	rt := r.names[name]
+	if rt == nil {
+		return "", fmt.Errorf("ki: no existe la ruta con nombre %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("ki: URL(%q) requiere pares clave/valor", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	var b strings.Builder
+	used := make(map[string]bool)
+	if rt.isPrefix {
+		b.WriteString(rt.prefix)
+	} else {
+	segments:
+		for _, seg := range rt.segments {
+			ps := parseSegment(seg)
+			switch ps.kind {
+			case segStatic:
+				b.WriteByte('/')
+				b.WriteString(seg)
+			case segMixed:
+				b.WriteByte('/')
+				for _, p := range ps.parts {
+					if p.name == "" {
+						b.WriteString(p.literal)
+						continue
+					}
+					v, err := urlParam(rt, name, values, p.name, p.expr)
+					if err != nil {
+						return "", err
+					}
+					b.WriteString(url.PathEscape(v))
+					used[p.name] = true
+				}
+			default:
+				if _, ok := values[ps.name]; !ok && ps.optional {
+					// Los segmentos opcionales son finales: se omite el resto
+					break segments
+				}
+				v, err := urlParam(rt, name, values, ps.name, ps.expr)
+				if err != nil {
+					return "", err
+				}
+				b.WriteByte('/')
+				if ps.kind == segCatchAll {
+					// El comodín conserva las barras: se escapa cada parte
+					parts := strings.Split(strings.TrimPrefix(v, "/"), "/")
+					for i, p := range parts {
+						parts[i] = url.PathEscape(p)
+					}
+					b.WriteString(strings.Join(parts, "/"))
+				} else {
+					b.WriteString(url.PathEscape(v))
+				}
+				used[ps.name] = true
+			}
+		}
+		if b.Len() == 0 {
+			b.WriteByte('/')
+		}
+	}
+
+	query := url.Values{}
+	for i := 0; i < len(params); i += 2 {
+		if !used[params[i]] {
+			query.Add(params[i], params[i+1])
+		}
+	}
+	if len(query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(query.Encode())
+	}
+	return b.String(), nil
+}
+
+// urlParam obtiene y valida el valor de una variable del patrón.
+func urlParam(rt *route, routeName string, values map[string]string, name, expr string) (string, error) {
+	v, ok := values[name]
+	if !ok {
+		return "", fmt.Errorf("ki: URL(%q) falta el parámetro %q", routeName, name)
+	}
+	if expr = rt.varRegex(name, expr); expr != "" && !rt.regexes[anchorRegex(expr)].MatchString(v) {
+		return "", fmt.Errorf("ki: URL(%q) el parámetro %q=%q no cumple %q", routeName, name, v, expr)
+	}
+	return v, nil
+}
+
+// setName registra el nombre de la ruta; un nombre repetido es un error de programación.
+func (r *router) setName(rt *route, name string) {
+	if prev, ok := r.names[name]; ok && prev != rt {
+		panic(fmt.Sprintf("ki: nombre de ruta duplicado %q (%s %s)", name, prev.method, prev.pattern))
+	}
+	if rt.name != "" && rt.name != name {
+		delete(r.names, rt.name)
+	}
+	rt.name = name
+	r.names[name] = rt
+}
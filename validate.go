@@ -0,0 +1,466 @@
+package ki
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidationRule valida un campo. v ya viene desreferenciado (salvo en
+// "required") y param es lo que sigue al "=" en el tag.
+type ValidationRule func(v reflect.Value, param string) bool
+
+// FieldViolation es un campo que no cumple una regla del tag validate.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError agrupa todos los campos inválidos. Si un handler lo devuelve
+// y no hay OnError, se responde 422 con el formato de ctx.Fail.
+type ValidationError struct {
+	Fields []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "ki: validación: " + strings.Join(msgs, "; ")
+}
+
+// Reglas incluidas por defecto en cada App.
+var defaultValidators = map[string]ValidationRule{
+	"required": func(v reflect.Value, _ string) bool {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			return !v.IsNil()
+		case reflect.Slice, reflect.Map:
+			return v.Len() > 0
+		}
+		return !v.IsZero()
+	},
+	"min": func(v reflect.Value, p string) bool {
+		n, ok := measure(v)
+		return ok && n >= ruleFloat(p)
+	},
+	"max": func(v reflect.Value, p string) bool {
+		n, ok := measure(v)
+		return ok && n <= ruleFloat(p)
+	},
+	"len": func(v reflect.Value, p string) bool {
+		n, ok := measure(v)
+		return ok && n == ruleFloat(p)
+	},
+	"email": func(v reflect.Value, _ string) bool {
+		addr, err := mail.ParseAddress(v.String())
+		return err == nil && addr.Name == "" && addr.Address == v.String()
+	},
+	"oneof": func(v reflect.Value, p string) bool {
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(p) {
+			if s == opt {
+				return true
+			}
+		}
+		return false
+	},
+	"regex": func(v reflect.Value, p string) bool {
+		return ruleRegex(p).MatchString(v.String())
+	},
+}
+
+// Mensajes por defecto; las reglas propias usan "no cumple la regla".
+var validationMessages = map[string]string{
+	"required": "es obligatorio",
+	"min":      "debe ser como mínimo %s",
+	"max":      "debe ser como máximo %s",
+	"len":      "debe tener longitud %s",
+	"email":    "debe ser un email válido",
+	"oneof":    "debe ser uno de: %s",
+	"regex":    "no tiene el formato esperado",
+}
+
+// RegisterValidation agrega (o reemplaza) una regla usable en el tag validate.
+//
+//	app.RegisterValidation("even", func(v reflect.Value, _ string) bool {
+//		return v.Int()%2 == 0
+//	})
+func (app *App) RegisterValidation(name string, rule ValidationRule) {
+	if name == "dive" || name == "omitempty" {
+		panic(fmt.Sprintf("ki: %q es una palabra reservada de validate", name))
+	}
+	app.validators[name] = rule
+	// Los tipos ya revisados se vuelven a revisar con la regla nueva
+	checkedRules.Range(func(k, _ any) bool {
+		checkedRules.Delete(k)
+		return true
+	})
+}
+
+// Validate aplica los tags validate del struct (o puntero a struct) v:
+//
+//	type Input struct {
+//		Name  string   `json:"name" validate:"required,min=3"`
+//		Role  string   `json:"role" validate:"oneof=admin user"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,min=2"`
+//		Code  string   `json:"code" validate:"omitempty,regex=^[A-Z]{3}$"`
+//	}
+//
+// "dive" aplica las reglas siguientes a cada elemento; los structs anidados se
+// validan siempre. "regex" debe ser la última regla (puede contener comas).
+// Devuelve un *ValidationError con todos los campos inválidos, o un error
+// común (500) si un tag no aplica al campo, como min=3 en un bool.
+func (s *Context) Validate(v any) error {
+	rules := defaultValidators
+	if s.App != nil && s.App.validators != nil {
+		rules = s.App.validators
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("ki: Validate requiere un struct, se recibió %T", v)
+	}
+	// Los tags mal escritos se detectan una vez por tipo, no en cada petición
+	if err := checkRules(rv.Type(), rules); err != nil {
+		return err
+	}
+	vd := &validator{rules: rules}
+	if err := vd.validateStruct(rv, ""); err != nil {
+		return err
+	}
+	if len(vd.errs) > 0 {
+		return &ValidationError{Fields: vd.errs}
+	}
+	return nil
+}
+
+// BindAndValidate hace Bind y luego Validate.
+func (s *Context) BindAndValidate(dst any) error {
+	if err := s.Bind(dst); err != nil {
+		return err
+	}
+	return s.Validate(dst)
+}
+
+// ----------- RECORRIDO DEL STRUCT -----------
+
+type validator struct {
+	rules map[string]ValidationRule
+	errs  []FieldViolation
+}
+
+type fieldRule struct {
+	name  string
+	param string
+}
+
+func (vd *validator) validateStruct(rv reflect.Value, prefix string) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		path := prefix
+		if !sf.Anonymous {
+			path = joinField(prefix, fieldLabel(sf))
+		}
+		if err := vd.validateValue(rv.Field(i), path, parseRules(tag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vd *validator) validateValue(fv reflect.Value, path string, rules []fieldRule) error {
+	dv := fv
+	for dv.Kind() == reflect.Ptr || dv.Kind() == reflect.Interface {
+		if dv.IsNil() {
+			dv = reflect.Value{}
+			break
+		}
+		dv = dv.Elem()
+	}
+	for i, r := range rules {
+		switch r.name {
+		case "omitempty":
+			if !dv.IsValid() || dv.IsZero() {
+				return nil
+			}
+			continue
+		case "dive":
+			return vd.dive(dv, path, rules[i+1:])
+		}
+		fn := vd.rules[r.name]
+		if fn == nil {
+			return fmt.Errorf("ki: regla de validación desconocida %q en %s", r.name, path)
+		}
+		target := dv
+		if r.name == "required" {
+			target = fv
+		} else if !dv.IsValid() {
+			// Punteros nil: sólo aplica required
+			return nil
+		}
+		if !fn(target, r.param) {
+			vd.errs = append(vd.errs, FieldViolation{
+				Field:   path,
+				Rule:    r.name,
+				Param:   r.param,
+				Message: violationMessage(r),
+			})
+			return nil
+		}
+	}
+	if dv.IsValid() && dv.Kind() == reflect.Struct && dv.Type() != timeType {
+		return vd.validateStruct(dv, path)
+	}
+	return nil
+}
+
+// dive valida cada elemento de un slice, array o map con las reglas restantes.
+func (vd *validator) dive(v reflect.Value, path string, rules []fieldRule) error {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := vd.validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), rules); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := vd.validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), rules); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("ki: dive requiere un slice o map en %s", path)
+	}
+	return nil
+}
+
+// ----------- HELPERS DE VALIDACIÓN -----------
+
+// parseRules separa el tag por comas; regex consume el resto del tag.
+func parseRules(tag string) []fieldRule {
+	var rules []fieldRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, fieldRule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+// fieldLabel es el nombre del campo en la petición (json, form, query...) o el del struct.
+func fieldLabel(sf reflect.StructField) string {
+	for _, key := range []string{"json", "xml", "form", "query", "path", "header"} {
+		if name := tagName(sf.Tag.Get(key)); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func joinField(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func violationMessage(r fieldRule) string {
+	msg, ok := validationMessages[r.name]
+	if !ok {
+		return fmt.Sprintf("no cumple la regla %q", r.name)
+	}
+	if strings.Contains(msg, "%s") {
+		return fmt.Sprintf(msg, r.param)
+	}
+	return msg
+}
+
+// measure devuelve el valor numérico o la longitud (strings en runas); ok es
+// false si el tipo no tiene tamaño (sólo pasa con campos interface).
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func measurable(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// ruleFloat parsea el parámetro de min/max/len; checkRules ya lo validó.
+func ruleFloat(p string) float64 {
+	f, err := strconv.ParseFloat(p, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// ----------- CHEQUEO DE LOS TAGS -----------
+
+type rulesKey struct {
+	t     reflect.Type
+	rules uintptr // identidad del mapa de reglas (cada App tiene el suyo)
+}
+
+var checkedRules sync.Map // rulesKey -> error
+
+// checkRules revisa una sola vez por tipo que los tags validate sean
+// aplicables: reglas conocidas, parámetros numéricos en min/max/len, tipos con
+// tamaño y regex que compilen. Las reglas reemplazadas con RegisterValidation
+// no se revisan.
+func checkRules(t reflect.Type, rules map[string]ValidationRule) error {
+	key := rulesKey{t, reflect.ValueOf(rules).Pointer()}
+	if err, ok := checkedRules.Load(key); ok {
+		err, _ := err.(error)
+		return err
+	}
+	err := checkStruct(t, t.Name(), rules, map[reflect.Type]bool{})
+	checkedRules.Store(key, err)
+	return err
+}
+
+func checkStruct(t reflect.Type, prefix string, rules map[string]ValidationRule, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		path := prefix
+		if !sf.Anonymous {
+			path = joinField(prefix, sf.Name)
+		}
+		if err := checkField(sf.Type, path, parseRules(tag), rules, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkField(t reflect.Type, path string, fieldRules []fieldRule, rules map[string]ValidationRule, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i, r := range fieldRules {
+		if r.name == "omitempty" {
+			continue
+		}
+		if r.name == "dive" {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				return checkField(t.Elem(), path+"[]", fieldRules[i+1:], rules, seen)
+			}
+			return fmt.Errorf("ki: validate en %s: dive requiere un slice o map, no %s", path, t)
+		}
+		fn := rules[r.name]
+		if fn == nil {
+			return fmt.Errorf("ki: regla de validación desconocida %q en %s", r.name, path)
+		}
+		if !isDefaultRule(r.name, fn) {
+			continue
+		}
+		if err := checkRule(t, r); err != nil {
+			return fmt.Errorf("ki: validate en %s: %w", path, err)
+		}
+	}
+	if t.Kind() == reflect.Struct && t != timeType {
+		return checkStruct(t, path, rules, seen)
+	}
+	return nil
+}
+
+func checkRule(t reflect.Type, r fieldRule) error {
+	switch r.name {
+	case "min", "max", "len":
+		if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+			return fmt.Errorf("%s=%s necesita un número", r.name, r.param)
+		}
+		if !measurable(t.Kind()) || t == timeType {
+			return fmt.Errorf("%s no aplica al tipo %s", r.name, t)
+		}
+	case "email":
+		if k := t.Kind(); k != reflect.String && k != reflect.Interface {
+			return fmt.Errorf("email no aplica al tipo %s", t)
+		}
+	case "regex":
+		if k := t.Kind(); k != reflect.String && k != reflect.Interface {
+			return fmt.Errorf("regex no aplica al tipo %s", t)
+		}
+		if _, err := regexp.Compile(r.param); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDefaultRule indica si fn es la regla incluida (no una reemplazada).
+func isDefaultRule(name string, fn ValidationRule) bool {
+	def, ok := defaultValidators[name]
+	return ok && reflect.ValueOf(def).Pointer() == reflect.ValueOf(fn).Pointer()
+}
+
+var ruleRegexes sync.Map
+
+// ruleRegex compila (una sola vez) la regex de la regla regex.
+func ruleRegex(expr string) *regexp.Regexp {
+	if re, ok := ruleRegexes.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	ruleRegexes.Store(expr, re)
+	return re
+}
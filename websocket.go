@@ -0,0 +1,501 @@
+package ki
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ------------- WEBSOCKET (RFC 6455) --------------
+//
+// WSConn implementa el framing de RFC 6455 sobre la conexión secuestrada:
+// mensajes de texto/binarios fragmentados, ping/pong, cierre con códigos,
+// límite de tamaño por mensaje y permessage-deflate (RFC 7692) sin
+// "context takeover".
+
+// Tipos de mensaje de ReadMessage/WriteMessage.
+const (
+	WSText   = 1
+	WSBinary = 2
+)
+
+// Códigos de cierre (RFC 6455, sección 7.4.1).
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseUnsupportedData = 1003
+	WSCloseNoStatus        = 1005
+	WSCloseAbnormal        = 1006
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseMessageTooBig   = 1009
+	WSCloseInternalError   = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// WSCloseError es el error de ReadMessage cuando la conexión se cierra con un
+// frame de cierre (propio o del otro extremo).
+type WSCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("ki: websocket cerrado (%d) %s", e.Code, e.Reason)
+}
+
+var errWSClosed = errors.New("ki: la conexión websocket está cerrada")
+
+// WSConn es una conexión WebSocket. ReadMessage debe usarse desde una sola
+// goroutine; las escrituras son seguras desde varias.
+type WSConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	server      bool
+	subprotocol string
+	compress    bool
+	maxSize     int64
+	fragment    int
+
+	writeMu   sync.Mutex
+	closeSent bool
+	onPing    func(data []byte)
+	onPong    func(data []byte)
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, server bool, o *wsOptions) *WSConn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &WSConn{
+		conn:     conn,
+		br:       br,
+		server:   server,
+		maxSize:  o.maxMessageSize,
+		fragment: o.fragmentSize,
+	}
+}
+
+// Subprotocol devuelve el subprotocolo negociado ("" si ninguno).
+func (c *WSConn) Subprotocol() string { return c.subprotocol }
+
+// Compressed indica si se negoció permessage-deflate.
+func (c *WSConn) Compressed() bool { return c.compress }
+
+func (c *WSConn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+func (c *WSConn) LocalAddr() net.Addr  { return c.conn.LocalAddr() }
+
+func (c *WSConn) SetReadDeadline(t time.Time) error  { return c.conn.SetReadDeadline(t) }
+func (c *WSConn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// SetReadLimit cambia el tamaño máximo de un mensaje (ya descomprimido).
+func (c *WSConn) SetReadLimit(n int64) { c.maxSize = n }
+
+// SetPingHandler se llama al recibir un ping (el pong se responde igual).
+func (c *WSConn) SetPingHandler(fn func(data []byte)) { c.onPing = fn }
+
+// SetPongHandler se llama al recibir un pong.
+func (c *WSConn) SetPongHandler(fn func(data []byte)) { c.onPong = fn }
+
+// ----------- LECTURA -----------
+
+// ReadMessage devuelve el siguiente mensaje completo (WSText o WSBinary),
+// respondiendo pings y uniendo fragmentos. Al recibir un cierre devuelve un
+// *WSCloseError; ante un error de protocolo cierra con el código que corresponde.
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	var (
+		msgType    int
+		compressed bool
+		buf        []byte
+	)
+	for {
+		fin, rsv1, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if op >= opClose {
+			if err := c.handleControl(op, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		if op == opContinuation {
+			if msgType == 0 {
+				return 0, nil, c.fail(WSCloseProtocolError, "continuación inesperada")
+			}
+		} else {
+			if msgType != 0 {
+				return 0, nil, c.fail(WSCloseProtocolError, "mensaje nuevo sin terminar el anterior")
+			}
+			msgType, compressed = int(op), rsv1
+		}
+		if c.maxSize > 0 && int64(len(buf)+len(payload)) > c.maxSize {
+			return 0, nil, c.fail(WSCloseMessageTooBig, "mensaje demasiado grande")
+		}
+		buf = append(buf, payload...)
+		if fin {
+			break
+		}
+	}
+	if compressed {
+		var err error
+		if buf, err = wsInflate(buf, c.maxSize); err != nil {
+			if errors.Is(err, errWSTooBig) {
+				return 0, nil, c.fail(WSCloseMessageTooBig, "mensaje demasiado grande")
+			}
+			return 0, nil, c.fail(WSCloseInvalidPayload, "deflate inválido")
+		}
+	}
+	if msgType == WSText && !utf8.Valid(buf) {
+		return 0, nil, c.fail(WSCloseInvalidPayload, "texto no es UTF-8 válido")
+	}
+	return msgType, buf, nil
+}
+
+// ReadJSON lee un mensaje y lo decodifica en v.
+func (c *WSConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *WSConn) readFrame() (fin, rsv1 bool, op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	rsv1 = h[0]&0x40 != 0
+	op = h[0] & 0x0F
+	masked := h[1]&0x80 != 0
+
+	switch {
+	case h[0]&0x30 != 0:
+		err = c.fail(WSCloseProtocolError, "bits RSV no negociados")
+		return
+	case rsv1 && (!c.compress || op == opContinuation || op >= opClose):
+		err = c.fail(WSCloseProtocolError, "RSV1 inesperado")
+		return
+	case op > opBinary && op < opClose || op > opPong:
+		err = c.fail(WSCloseProtocolError, "opcode desconocido")
+		return
+	case masked != c.server:
+		// El cliente siempre enmascara; el servidor nunca
+		err = c.fail(WSCloseProtocolError, "enmascarado incorrecto")
+		return
+	}
+
+	n := int64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+		if n < 0 {
+			err = c.fail(WSCloseProtocolError, "longitud inválida")
+			return
+		}
+	}
+	if op >= opClose && (n > 125 || !fin) {
+		err = c.fail(WSCloseProtocolError, "frame de control inválido")
+		return
+	}
+	if c.maxSize > 0 && n > c.maxSize {
+		err = c.fail(WSCloseMessageTooBig, "mensaje demasiado grande")
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return
+}
+
+func (c *WSConn) handleControl(op byte, payload []byte) error {
+	switch op {
+	case opPing:
+		if c.onPing != nil {
+			c.onPing(payload)
+		}
+		if err := c.writeControl(opPong, payload); err != nil && !errors.Is(err, errWSClosed) {
+			return err
+		}
+	case opPong:
+		if c.onPong != nil {
+			c.onPong(payload)
+		}
+	case opClose:
+		code, reason := WSCloseNoStatus, ""
+		switch {
+		case len(payload) == 1:
+			return c.fail(WSCloseProtocolError, "frame de cierre inválido")
+		case len(payload) >= 2:
+			code = int(binary.BigEndian.Uint16(payload))
+			reason = string(payload[2:])
+			if !validCloseCode(code) || !utf8.ValidString(reason) {
+				return c.fail(WSCloseProtocolError, "código de cierre inválido")
+			}
+		}
+		// Responde el cierre (si no lo inició este extremo) y libera la conexión
+		echo := code
+		if echo == WSCloseNoStatus {
+			echo = WSCloseNormal
+		}
+		c.sendClose(echo, "")
+		c.conn.Close()
+		return &WSCloseError{Code: code, Reason: reason}
+	}
+	return nil
+}
+
+// fail cierra la conexión con code y devuelve el error correspondiente.
+func (c *WSConn) fail(code int, reason string) error {
+	c.sendClose(code, reason)
+	c.conn.Close()
+	return &WSCloseError{Code: code, Reason: reason}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// ----------- ESCRITURA -----------
+
+// WriteMessage envía un mensaje WSText o WSBinary, comprimido si se negoció
+// permessage-deflate y fragmentado según WSFragmentSize.
+func (c *WSConn) WriteMessage(msgType int, data []byte) error {
+	if msgType != WSText && msgType != WSBinary {
+		return fmt.Errorf("ki: tipo de mensaje websocket inválido %d", msgType)
+	}
+	compressed := false
+	if c.compress {
+		var err error
+		if data, err = wsDeflate(data); err != nil {
+			return err
+		}
+		compressed = true
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errWSClosed
+	}
+	op := byte(msgType)
+	for first := true; ; first = false {
+		chunk := data
+		if c.fragment > 0 && len(chunk) > c.fragment {
+			chunk = data[:c.fragment]
+		}
+		data = data[len(chunk):]
+		if err := c.writeFrame(len(data) == 0, compressed && first, op, chunk); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		op = opContinuation
+	}
+}
+
+// WriteText envía un mensaje de texto.
+func (c *WSConn) WriteText(s string) error {
+	return c.WriteMessage(WSText, []byte(s))
+}
+
+// WriteJSON envía v como mensaje de texto JSON.
+func (c *WSConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(WSText, data)
+}
+
+// Ping envía un ping; la respuesta llega al SetPongHandler mientras se lee.
+func (c *WSConn) Ping(data []byte) error {
+	return c.writeControl(opPing, data)
+}
+
+// CloseWithCode envía el frame de cierre y cierra la conexión.
+func (c *WSConn) CloseWithCode(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	err := c.sendClose(code, reason)
+	if cerr := c.conn.Close(); err == nil && !errors.Is(cerr, net.ErrClosed) {
+		err = cerr
+	}
+	if errors.Is(err, errWSClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close cierra la conexión con WSCloseNormal.
+func (c *WSConn) Close() error {
+	return c.CloseWithCode(WSCloseNormal, "")
+}
+
+func (c *WSConn) sendClose(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errWSClosed
+	}
+	c.closeSent = true
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.writeFrame(true, false, opClose, payload)
+}
+
+func (c *WSConn) writeControl(op byte, data []byte) error {
+	if len(data) > 125 {
+		return errors.New("ki: el payload de control no puede superar 125 bytes")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return errWSClosed
+	}
+	return c.writeFrame(true, false, op, data)
+}
+
+// writeFrame escribe un frame; el cliente enmascara el payload. Requiere writeMu.
+func (c *WSConn) writeFrame(fin, rsv1 bool, op byte, payload []byte) error {
+	hdr := make([]byte, 0, 14+len(payload))
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	hdr = append(hdr, b0)
+
+	var maskBit byte
+	if !c.server {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		hdr = append(hdr, maskBit|byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, maskBit|126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr = append(hdr, maskBit|127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	if c.server {
+		hdr = append(hdr, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		hdr = append(hdr, mask[:]...)
+		start := len(hdr)
+		hdr = append(hdr, payload...)
+		maskBytes(mask, hdr[start:])
+	}
+	_, err := c.conn.Write(hdr)
+	return err
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i&3]
+	}
+}
+
+// ----------- PERMESSAGE-DEFLATE -----------
+
+var (
+	errWSTooBig = errors.New("ki: mensaje websocket demasiado grande")
+
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+	// Cola que RFC 7692 quita al comprimir, más un bloque final vacío
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+)
+
+func wsDeflate(p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(fw)
+	fw.Reset(&buf)
+	if _, err := fw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail[:4]), nil
+}
+
+func wsInflate(p []byte, limit int64) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+	var r io.Reader = fr
+	if limit > 0 {
+		r = io.LimitReader(fr, limit+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, errWSTooBig
+	}
+	return out, nil
+}
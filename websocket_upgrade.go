@@ -0,0 +1,325 @@
+package ki
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// wsGUID es el GUID fijo de RFC 6455 para calcular Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+type wsOptions struct {
+	subprotocols   []string
+	checkOrigin    func(r *http.Request) bool
+	maxMessageSize int64
+	compression    bool
+	fragmentSize   int
+}
+
+// WSOption configura Upgrade, RouteBuilder.WebSocket y DialWebSocket.
+type WSOption func(o *wsOptions)
+
+// WSSubprotocols son los subprotocolos soportados, en orden de preferencia.
+func WSSubprotocols(protocols ...string) WSOption {
+	return func(o *wsOptions) {
+		o.subprotocols = protocols
+	}
+}
+
+// WSCheckOrigin reemplaza la validación de Origin (por defecto, mismo host).
+func WSCheckOrigin(fn func(r *http.Request) bool) WSOption {
+	return func(o *wsOptions) {
+		o.checkOrigin = fn
+	}
+}
+
+// WSMaxMessageSize limita el tamaño de cada mensaje recibido (por defecto 16 MiB).
+// Un mensaje mayor cierra la conexión con WSCloseMessageTooBig.
+func WSMaxMessageSize(n int64) WSOption {
+	return func(o *wsOptions) {
+		o.maxMessageSize = n
+	}
+}
+
+// WSCompression habilita permessage-deflate si el otro extremo lo ofrece.
+func WSCompression(enabled bool) WSOption {
+	return func(o *wsOptions) {
+		o.compression = enabled
+	}
+}
+
+// WSFragmentSize divide los mensajes enviados en frames de hasta n bytes.
+func WSFragmentSize(n int) WSOption {
+	return func(o *wsOptions) {
+		o.fragmentSize = n
+	}
+}
+
+func newWSOptions(opts []WSOption) *wsOptions {
+	o := &wsOptions{maxMessageSize: 16 << 20}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ----------- SERVIDOR -----------
+
+// Upgrade completa el handshake de WebSocket y devuelve la conexión, que
+// también queda disponible para DI como *ki.WSConn. Si la petición no es un
+// handshake válido devuelve un *HTTPError sin escribir la respuesta (basta con
+// devolverlo desde el handler). Quien llama es dueño de la conexión y debe cerrarla.
+func (s *Context) Upgrade(opts ...WSOption) (*WSConn, error) {
+	o := newWSOptions(opts)
+	r := s.Request
+
+	if r.Method != http.MethodGet {
+		return nil, NewHTTPError(http.StatusMethodNotAllowed, "websocket: el handshake requiere GET")
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, BadRequest("websocket: faltan los headers Connection/Upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		s.Writer.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, NewHTTPError(http.StatusUpgradeRequired, "websocket: versión no soportada")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 16 {
+		return nil, BadRequest("websocket: Sec-WebSocket-Key inválida")
+	}
+	checkOrigin := o.checkOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, Forbidden("websocket: origen no permitido")
+	}
+
+	subprotocol := ""
+	for _, offered := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		if containsString(o.subprotocols, offered) {
+			subprotocol = offered
+			break
+		}
+	}
+	compress := o.compression && offersDeflate(r.Header)
+
+	skipCache(s.Writer)
+	conn, brw, err := http.NewResponseController(s.Writer).Hijack()
+	if err != nil {
+		return nil, InternalError(fmt.Errorf("websocket: %w", err))
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	b.WriteString("\r\n")
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := newWSConn(conn, brw.Reader, true, o)
+	c.subprotocol = subprotocol
+	c.compress = compress
+	s.injector.Map(c)
+	return c, nil
+}
+
+// WebSocket registra una ruta GET que hace el Upgrade e invoca fn con DI;
+// fn puede recibir *ki.WSConn. La conexión se cierra al retornar fn (con
+// WSCloseInternalError si devuelve un error).
+//
+//	app.Path("/ws").WebSocket(func(conn *ki.WSConn, hub *Hub) error {
+//		for {
+//			_, msg, err := conn.ReadMessage()
+//			if err != nil {
+//				return nil
+//			}
+//			hub.Broadcast(msg)
+//		}
+//	})
+func (rb *RouteBuilder) WebSocket(fn HandlerFunc, opts ...WSOption) *RouteBuilder {
+	return rb.Method(http.MethodGet).Handle(func(ctx *Context) error {
+		conn, err := ctx.Upgrade(opts...)
+		if err != nil {
+			return err
+		}
+		if err := invokeHandler(ctx, fn); err != nil {
+			conn.CloseWithCode(WSCloseInternalError, err.Error())
+			return nil
+		}
+		conn.Close()
+		return nil
+	})
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// headerTokens devuelve los valores separados por coma de un header.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// offersDeflate indica si el header ofrece permessage-deflate con parámetros
+// compatibles (ventana de 15 bits; sin context takeover lo impone el servidor).
+func offersDeflate(h http.Header) bool {
+	for _, ext := range headerTokens(h, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			switch k {
+			case "client_max_window_bits", "server_no_context_takeover", "client_no_context_takeover":
+			case "server_max_window_bits":
+				ok = ok && strings.Trim(v, `"`) == "15"
+			default:
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------- CLIENTE -----------
+
+// DialWebSocket abre una conexión WebSocket como cliente (ws://, wss://,
+// http:// o https://). Útil para tests y para hablar con otros servicios.
+//
+//	conn, _, err := ki.DialWebSocket(ctx, "ws://localhost:8080/ws", nil, ki.WSCompression(true))
+func DialWebSocket(ctx context.Context, rawURL string, header http.Header, opts ...WSOption) (*WSConn, *http.Response, error) {
+	o := newWSOptions(opts)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, secure = "https", true
+	default:
+		return nil, nil, fmt.Errorf("ki: esquema websocket no soportado %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var conn net.Conn
+	if secure {
+		d := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	key := base64.StdEncoding.EncodeToString(raw)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(o.subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(o.subprotocols, ", "))
+	}
+	if o.compression {
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; client_no_context_takeover; server_no_context_takeover")
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerHasToken(resp.Header, "Upgrade", "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, resp, fmt.Errorf("ki: handshake websocket fallido: %s", resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := newWSConn(conn, br, false, o)
+	c.subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	c.compress = o.compression && offersDeflate(resp.Header)
+	return c, resp, nil
+}